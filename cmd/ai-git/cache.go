@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/eliau2005/ai-git/internal/cache"
+	"github.com/eliau2005/ai-git/internal/config"
+	"github.com/eliau2005/ai-git/internal/git"
+	"github.com/eliau2005/ai-git/internal/provider"
+)
+
+func handleCache(args []string) {
+	if len(args) == 0 || args[0] != "clear" {
+		fmt.Println("Usage: ai-git cache clear")
+		return
+	}
+
+	res, err := resolveSettings()
+	if err != nil {
+		fmt.Println(styleError.Render(fmt.Sprintf("Config Error: %v", err)))
+		return
+	}
+	c, err := openCache(res.Config)
+	if err != nil {
+		fmt.Println(styleError.Render(fmt.Sprintf("Cache error: %v", err)))
+		return
+	}
+	count, err := c.Clear()
+	if err != nil {
+		fmt.Println(styleError.Render(fmt.Sprintf("Failed to clear cache: %v", err)))
+		return
+	}
+	fmt.Println(styleSuccess.Render(fmt.Sprintf("Removed %d cached responses.", count)))
+}
+
+func openCache(cfg *config.Config) (*cache.Cache, error) {
+	gitDir, _ := git.GetGitCommonDir()
+	dir, err := cache.GetCacheDir(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	return &cache.Cache{
+		Dir:      dir,
+		TTL:      cfg.Cache.TTL,
+		MaxBytes: int64(cfg.Cache.MaxSizeMB) << 20,
+	}, nil
+}
+
+// responseCache returns the cache to use for AI responses, or nil when
+// disabled in the config or by --no-cache.
+func responseCache(cfg *config.Config) *cache.Cache {
+	if cfg.Cache.Disabled {
+		return nil
+	}
+	c, err := openCache(cfg)
+	if err != nil {
+		return nil
+	}
+	return c
+}
+
+func hasFlag(name string) bool {
+	for _, arg := range os.Args[2:] {
+		if arg == name {
+			return true
+		}
+	}
+	return false
+}
+
+// responseKey is the cache key for a response from p with model. Providers
+// are named after their config entry, so two entries of the same kind
+// (say, two Ollama hosts) never share responses.
+func responseKey(p provider.Provider, model string, parts ...string) string {
+	return cache.Key(append([]string{p.GetName(), model}, parts...)...)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/eliau2005/ai-git/internal/config"
+	"github.com/eliau2005/ai-git/internal/provider"
+)
+
+func TestResponseKey(t *testing.T) {
+	factory := &provider.ProviderFactory{}
+	build := func(name, host string) provider.Provider {
+		return factory.GetProvider(name, config.ProviderConfig{Type: "ollama", BaseURL: host}, "llama3", "", "")
+	}
+	local := build("local", "http://localhost:11434")
+	gpu := build("gpu", "http://gpu-box:11434")
+
+	if responseKey(local, "llama3", "diff") == responseKey(gpu, "llama3", "diff") {
+		t.Error("two ollama entries share a cache key")
+	}
+	if responseKey(local, "llama3", "diff") == responseKey(local, "mistral", "diff") {
+		t.Error("two models of one entry share a cache key")
+	}
+	if responseKey(local, "llama3", "diff") != responseKey(build("local", "http://localhost:11434"), "llama3", "diff") {
+		t.Error("the same entry and model got different keys")
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/eliau2005/ai-git/internal/config"
+	"github.com/eliau2005/ai-git/internal/provider"
+)
+
+const maxCandidates = 5
+
+// candidateCount is output.candidates, which --candidates N (or -n N)
+// sets, kept between 1 and maxCandidates.
+func candidateCount(cfg *config.Config) int {
+	n := cfg.Output.Candidates
+	if n < 1 {
+		n = 1
+	}
+	if n > maxCandidates {
+		n = maxCandidates
+	}
+	return n
+}
+
+// commitStyle returns the effective output.style, which config.Resolve has
+// already folded the repository's commit_style into, defaulting to
+// conventional commits.
+func commitStyle(cfg *config.Config) string {
+	if cfg.Output.Style != "" {
+		return cfg.Output.Style
+	}
+	return "conventional"
+}
+
+// commitRules collects the commit message rules of the effective config.
+func commitRules(cfg *config.Config) provider.CommitRules {
+	return provider.CommitRules{
+		Style:      commitStyle(cfg),
+		Language:   cfg.Output.Language,
+		MaxSubject: cfg.Output.MaxSubjectLength,
+		Types:      cfg.Output.Types,
+	}
+}
+
+// chooseCandidate shows the candidates side by side and lets the user pick
+// one, merge several in the editor, or ask for a regeneration. It returns
+// the chosen message and one of "use", "regenerate" or "cancel".
+func chooseCandidate(candidates []string) (string, string) {
+	if len(candidates) == 1 {
+		return candidates[0], "use"
+	}
+
+	const perRow = 3
+	const width = 34
+	labelStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("212"))
+	boxStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		Padding(0, 1).
+		Width(width)
+
+	var rows []string
+	for start := 0; start < len(candidates); start += perRow {
+		var boxes []string
+		for i := start; i < start+perRow && i < len(candidates); i++ {
+			title, description := splitMessage(candidates[i])
+			boxes = append(boxes, boxStyle.Render(lipgloss.JoinVertical(lipgloss.Left,
+				labelStyle.Render(fmt.Sprintf("#%d", i+1)),
+				lipgloss.NewStyle().Bold(true).Render(title),
+				"",
+				description,
+			)))
+		}
+		rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top, boxes...))
+	}
+	fmt.Println(lipgloss.NewStyle().MarginTop(1).Render(lipgloss.JoinVertical(lipgloss.Left, rows...)))
+
+	var options []huh.Option[string]
+	for i := range candidates {
+		options = append(options, huh.NewOption(fmt.Sprintf("Use #%d", i+1), strconv.Itoa(i)))
+	}
+	options = append(options,
+		huh.NewOption("Merge several in Editor", "merge"),
+		huh.NewOption("Regenerate with instruction", "regenerate"),
+		huh.NewOption("Cancel", "cancel"),
+	)
+
+	var choice string
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title("Pick a candidate").
+				Options(options...).
+				Value(&choice),
+		),
+	)
+	if err := form.Run(); err != nil {
+		return "", "cancel"
+	}
+
+	switch choice {
+	case "cancel", "regenerate":
+		return "", choice
+	case "merge":
+		merged, err := mergeCandidates(candidates)
+		if err != nil {
+			fmt.Println(styleError.Render(fmt.Sprintf("Editor error: %v", err)))
+			return chooseCandidate(candidates)
+		}
+		return merged, "use"
+	}
+
+	i, _ := strconv.Atoi(choice)
+	return candidates[i], "use"
+}
+
+// mergeCandidates opens the selected candidates together in the editor so
+// the user can stitch one message out of them. Lines starting with '#' are dropped.
+func mergeCandidates(candidates []string) (string, error) {
+	var picked []string
+	var options []huh.Option[string]
+	for i, c := range candidates {
+		title, _ := splitMessage(c)
+		options = append(options, huh.NewOption(fmt.Sprintf("#%d %s", i+1, title), strconv.Itoa(i)).Selected(true))
+	}
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewMultiSelect[string]().
+				Title("Candidates to merge").
+				Options(options...).
+				Value(&picked),
+		),
+	)
+	if err := form.Run(); err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# Combine the parts you want into one commit message.\n# Lines starting with '#' are ignored.\n")
+	for _, idx := range picked {
+		i, _ := strconv.Atoi(idx)
+		sb.WriteString(fmt.Sprintf("\n# ---- Candidate #%d ----\n%s\n", i+1, strings.TrimSpace(candidates[i])))
+	}
+
+	edited, err := openInEditor(sb.String())
+	if err != nil {
+		return "", err
+	}
+
+	var kept []string
+	for _, line := range strings.Split(edited, "\n") {
+		if !strings.HasPrefix(line, "#") {
+			kept = append(kept, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n")), nil
+}
+
+// askInstruction prompts for a free-text steer for the next generation,
+// e.g. "shorter, mention the migration".
+func askInstruction(previous string) string {
+	instruction := previous
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("How should the message change?").
+				Placeholder("e.g. shorter, mention the migration").
+				Value(&instruction),
+		),
+	)
+	if err := form.Run(); err != nil {
+		return previous
+	}
+	return strings.TrimSpace(instruction)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/eliau2005/ai-git/internal/config"
+	"github.com/eliau2005/ai-git/internal/git"
+	"gopkg.in/yaml.v3"
+)
+
+const configUsage = "Usage: ai-git config get|set|unset|list [--repo|--global] [--reveal] <dotted.key> [value]"
+
+// handleConfigCommand runs `ai-git config get|set|unset|list`. Failures
+// exit with status 1 so provisioning scripts notice them.
+func handleConfigCommand(sub string, args []string) {
+	if err := runConfigCommand(sub, args); err != nil {
+		fmt.Fprintln(os.Stderr, styleError.Render(err.Error()))
+		os.Exit(1)
+	}
+}
+
+func runConfigCommand(sub string, args []string) error {
+	scope := ""
+	reveal := false
+	var rest []string
+	for _, arg := range args {
+		if arg == "--reveal" {
+			reveal = true
+		} else if arg == "--repo" || arg == "--global" {
+			if scope != "" && scope != arg {
+				return errors.New("use only one of --repo and --global")
+			}
+			scope = arg
+		} else {
+			rest = append(rest, arg)
+		}
+	}
+	want := map[string]int{"get": 1, "unset": 1, "set": 2, "list": 0}[sub]
+	if len(rest) != want || (reveal && sub != "get") {
+		return errors.New(configUsage)
+	}
+
+	// Without a scope, get and list show the effective values
+	if scope == "" && (sub == "get" || sub == "list") {
+		res, err := resolveSettings()
+		if err != nil {
+			return err
+		}
+		if sub == "list" {
+			for _, s := range res.Settings() {
+				fmt.Printf("%s = %s\n", s.Key, displayValue(s.Key, s.Value))
+			}
+			return nil
+		}
+		return printConfigValue(res.Config, rest[0], reveal)
+	}
+
+	target, save, where, err := configTarget(scope)
+	if errors.Is(err, config.ErrSecretsLocked) && (sub == "get" || sub == "list") {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	switch sub {
+	case "get":
+		return printConfigValue(target, rest[0], reveal)
+	case "list":
+		settings, err := config.Flatten(target)
+		if err != nil {
+			return err
+		}
+		for _, s := range settings {
+			fmt.Printf("%s = %s\n", s.Key, displayValue(s.Key, s.Value))
+		}
+		return nil
+	case "set":
+		if err := config.SetValue(target, rest[0], rest[1]); err != nil {
+			return err
+		}
+	case "unset":
+		if err := config.UnsetValue(target, rest[0]); err != nil {
+			return err
+		}
+	}
+	if err := save(); err != nil {
+		return fmt.Errorf("saving %s: %w", where, err)
+	}
+	fmt.Println(styleSuccess.Render(fmt.Sprintf("Updated %s in %s.", rest[0], where)))
+	return nil
+}
+
+// configTarget loads the file a scope edits: the global config by
+// default, or .ai-git.yaml with --repo.
+func configTarget(scope string) (any, func() error, string, error) {
+	if scope != "--repo" {
+		cfg, err := config.LoadConfig()
+		if cfg == nil {
+			return nil, nil, "", err
+		}
+		// A locked store is left for the caller to judge
+		return cfg, cfg.Save, "the global config", err
+	}
+
+	root, err := git.GetRepoRoot()
+	if err != nil {
+		return nil, nil, "", errors.New("--repo needs a git repository")
+	}
+	repoCfg, err := config.LoadRepoConfig(root)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf(".ai-git.yaml: %w", err)
+	}
+	if repoCfg == nil {
+		repoCfg = &config.RepoConfig{}
+	}
+	save := func() error { return config.SaveRepoConfig(root, repoCfg) }
+	return repoCfg, save, ".ai-git.yaml", nil
+}
+
+// printConfigValue prints key's value with keys, tokens and headers
+// masked, in sections too, unless reveal is set.
+func printConfigValue(target any, key string, reveal bool) error {
+	v, ok, err := config.GetValue(target, key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%s is not set", key)
+	}
+	if !reveal {
+		v = maskSecrets(key, v)
+	}
+	fmt.Println(formatConfigValue(v))
+	return nil
+}
+
+// maskSecrets masks v if key is a secret, or the secrets inside v if it's
+// a section, keeping its layout.
+func maskSecrets(key string, v any) any {
+	if s, ok := v.(string); ok {
+		if isSecretKey(key) {
+			return maskSecret(s)
+		}
+		return s
+	}
+	switch reflect.Indirect(reflect.ValueOf(v)).Kind() {
+	case reflect.Map, reflect.Struct:
+		var n yaml.Node
+		if err := n.Encode(v); err != nil {
+			return v
+		}
+		maskNode(key, &n)
+		return &n
+	}
+	return v
+}
+
+func maskNode(key string, n *yaml.Node) {
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := key+"."+n.Content[i].Value, n.Content[i+1]
+		if v.Kind == yaml.ScalarNode && isSecretKey(k) {
+			v.Value, v.Style = maskSecret(v.Value), 0
+		} else {
+			maskNode(k, v)
+		}
+	}
+}
+
+// formatConfigValue prints scalars as is, lists the way `set` takes them
+// and sections as YAML.
+func formatConfigValue(v any) string {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+		v = rv.Interface()
+	}
+	switch rv.Kind() {
+	case reflect.Map, reflect.Struct:
+		data, err := yaml.Marshal(v)
+		if err == nil {
+			return strings.TrimRight(string(data), "\n")
+		}
+	case reflect.Slice:
+		items := make([]string, rv.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(rv.Index(i).Interface())
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(v)
+}
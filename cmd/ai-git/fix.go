@@ -6,6 +6,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/eliau2005/ai-git/internal/prompt"
 	"github.com/eliau2005/ai-git/internal/provider"
 )
 
@@ -33,23 +34,31 @@ func handleFix(args []string) {
 		return
 	}
 
-	activeProv := getActiveProvider()
+	activeProv, prompts := getActiveProvider("fix")
 	chatter, ok := activeProv.(provider.Chatter)
 	if !ok {
 		fmt.Println(styleError.Render("Current provider does not support chat/diagnostics."))
 		return
 	}
 
-	prompt := "I encountered the following error while working in my repository. Please analyze the error, explain what might be causing it, and provide the exact fix or shell commands to resolve it:\n\n" + errorText
+	fixPrompt, err := prompts.Render(prompt.Fix, prompt.Data{Error: errorText})
+	if err != nil {
+		fmt.Println(styleError.Render(err.Error()))
+		return
+	}
 
 	fmt.Println(styleSubtle.Render("\nAnalyzing error...\n"))
 
-	err := chatter.AskChatStream(prompt, "", func(chunk string) {
+	ctx, stop := interruptContext()
+	defer stop()
+	err = chatter.AskChatStream(ctx, []provider.Message{{Role: "user", Content: fixPrompt}}, "", func(chunk string) {
 		fmt.Print(chunk)
 	})
 	fmt.Println()
 
 	if err != nil {
 		fmt.Println(styleError.Render(fmt.Sprintf("\nDiagnostics failed: %v", err)))
+		return
 	}
+	reportFallback(activeProv)
 }
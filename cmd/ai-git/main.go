@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -16,6 +21,7 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/eliau2005/ai-git/internal/config"
 	"github.com/eliau2005/ai-git/internal/git"
+	"github.com/eliau2005/ai-git/internal/prompt"
 	"github.com/eliau2005/ai-git/internal/provider"
 )
 
@@ -34,6 +40,9 @@ func main() {
 	}
 
 	command := os.Args[1]
+	recordUsage(command)
+	config.PassphrasePrompt = askPassphrase
+	defer provider.ClosePlugins()
 
 	switch command {
 	case "status":
@@ -80,6 +89,14 @@ func main() {
 		handleHook()
 	case "generate":
 		handleGenerate()
+	case "models":
+		handleModels(os.Args[2:])
+	case "usage":
+		handleUsage(os.Args[2:])
+	case "cache":
+		handleCache(os.Args[2:])
+	case "secrets":
+		handleSecrets(os.Args[2:])
 	case "version":
 		fmt.Println("ai-git version 1.4.0")
 	default:
@@ -109,9 +126,14 @@ func printUsage() {
 	fmt.Println("  fix     Diagnose and auto-fix piped shell errors")
 	fmt.Println("  index   Index the repository for AI chat")
 	fmt.Println("  chat    Chat with your repository codebase")
-	fmt.Println("  config  Manage configuration (run without args for interactive mode)")
+	fmt.Println("  config  Manage configuration (run without args for interactive mode, show [--origin] for effective values)")
+	fmt.Println("          config get|set|unset|list [--repo|--global] <dotted.key> [value]")
 	fmt.Println("  auth    Authenticate with platforms (GitHub/GitLab)")
 	fmt.Println("  doctor  Validate setup")
+	fmt.Println("  models  List models offered by each provider (--refresh)")
+	fmt.Println("  usage   Report token usage and estimated cost (--days N)")
+	fmt.Println("  cache   Clear cached AI responses (cache clear)")
+	fmt.Println("  secrets Encrypt stored API keys and tokens (secrets migrate [--passphrase])")
 	fmt.Println("  version Show version info")
 }
 
@@ -119,27 +141,39 @@ func printUsage() {
 
 type actionSpinnerModel struct {
 	spinner spinner.Model
-	action  func() error
+	action  func(ctx context.Context) error
+	ctx     context.Context
+	cancel  context.CancelFunc
 	err     error
-	done      bool
+	done    bool
 	title   string
 }
 
-func newActionSpinner(title string, action func() error) actionSpinnerModel {
+func newActionSpinner(title string, action func(ctx context.Context) error) actionSpinnerModel {
 	s := spinner.New()
 	s.Spinner = spinner.Dot
 	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
-	return actionSpinnerModel{spinner: s, action: action, title: title}
+	ctx, cancel := context.WithCancel(context.Background())
+	return actionSpinnerModel{spinner: s, action: action, ctx: ctx, cancel: cancel, title: title}
 }
 
 func (m actionSpinnerModel) Init() tea.Cmd {
 	return tea.Batch(m.spinner.Tick, func() tea.Msg {
-		return m.action()
+		return m.action(m.ctx)
 	})
 }
 
 func (m actionSpinnerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if msg.Type == tea.KeyCtrlC {
+			// Abort the in-flight request; the action goroutine sees ctx.Done()
+			m.cancel()
+			m.err = context.Canceled
+			m.done = true
+			return m, tea.Quit
+		}
+		return m, nil
 	case error:
 		m.err = msg
 		m.done = true
@@ -164,8 +198,9 @@ func (m actionSpinnerModel) View() string {
 	return fmt.Sprintf(" %s %s", m.spinner.View(), m.title)
 }
 
-func runSpinner(title string, action func() error) error {
+func runSpinner(title string, action func(ctx context.Context) error) error {
 	m := newActionSpinner(title, action)
+	defer m.cancel()
 	p := tea.NewProgram(m)
 	finalModel, err := p.Run()
 	if err != nil {
@@ -175,6 +210,12 @@ func runSpinner(title string, action func() error) error {
 	return finalState.err
 }
 
+// interruptContext returns a context cancelled by Ctrl+C, for streaming
+// output outside of bubbletea where the terminal is not in raw mode.
+func interruptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt)
+}
+
 // --- Commands ---
 
 func handleBranch() {
@@ -378,7 +419,7 @@ func handleAdd() {
 		return
 	}
 
-	err = runSpinner("Staging files...", func() error {
+	err = runSpinner("Staging files...", func(ctx context.Context) error {
 		for i := range fm.selected {
 			if err := git.Add(fm.files[i]); err != nil {
 				return err
@@ -525,49 +566,88 @@ func (m interactiveAddModel) View() string {
 // --- Spinner for AI (Specific) ---
 
 
+// generateFunc produces one or more candidate texts; it may report status
+// lines (e.g. map-reduce progress) through progress.
+type generateFunc func(ctx context.Context, progress func(string)) ([]string, error)
+
 type aiSpinnerModel struct {
 	spinner    spinner.Model
-	diff       string
-	context    string
-	provider   provider.Provider
-	msgResult  string
+	generate   generateFunc
+	progress   chan string
+	status     string
+	ctx        context.Context
+	cancel     context.CancelFunc
+	results    []string
 	err        error
 	done       bool
 	tokenCount int
 }
 
-func initialAISpinner(p provider.Provider, diff string, context string) aiSpinnerModel {
+func initialAISpinner(generate generateFunc, tokens int) aiSpinnerModel {
 	s := spinner.New()
 	s.Spinner = spinner.Dot
 	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
-	tokens := estimateTokens(diff + context)
-	return aiSpinnerModel{spinner: s, diff: diff, context: context, provider: p, tokenCount: tokens}
+	ctx, cancel := context.WithCancel(context.Background())
+	return aiSpinnerModel{
+		spinner:    s,
+		generate:   generate,
+		progress:   make(chan string, 16),
+		status:     "AI is thinking...",
+		ctx:        ctx,
+		cancel:     cancel,
+		tokenCount: tokens,
+	}
 }
 
 func (m aiSpinnerModel) Init() tea.Cmd {
-	return tea.Batch(m.spinner.Tick, func() tea.Msg {
-		msg, err := m.provider.GenerateCommitMessage(m.diff, m.context)
-		return msgGeneratedMsg{msg: msg, err: err}
+	return tea.Batch(m.spinner.Tick, m.waitForProgress(), func() tea.Msg {
+		msgs, err := m.generate(m.ctx, func(status string) {
+			// Drop updates rather than block the generator if the UI lags
+			select {
+			case m.progress <- status:
+			default:
+			}
+		})
+		return msgGeneratedMsg{msgs: msgs, err: err}
 	})
 }
 
 type msgGeneratedMsg struct {
-	msg string
-	err error
+	msgs []string
+	err  error
+}
+
+type aiProgressMsg string
+
+func (m aiSpinnerModel) waitForProgress() tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case status := <-m.progress:
+			return aiProgressMsg(status)
+		case <-m.ctx.Done():
+			return nil
+		}
+	}
 }
 
 func (m aiSpinnerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		if msg.Type == tea.KeyCtrlC {
+			m.cancel()
+			m.err = context.Canceled
+			m.done = true
 			return m, tea.Quit
 		}
 	case spinner.TickMsg:
 		var cmd tea.Cmd
 		m.spinner, cmd = m.spinner.Update(msg)
 		return m, cmd
+	case aiProgressMsg:
+		m.status = string(msg)
+		return m, m.waitForProgress()
 	case msgGeneratedMsg:
-		m.msgResult = msg.msg
+		m.results = msg.msgs
 		m.err = msg.err
 		m.done = true
 		return m, tea.Quit
@@ -585,70 +665,117 @@ func (m aiSpinnerModel) View() string {
 	}
 	tokenBadge := tokenStyle.Render(fmt.Sprintf(" (~%d tokens)", m.tokenCount))
 	
-	return fmt.Sprintf("\n %s AI is thinking...%s\n\n", m.spinner.View(), tokenBadge)
+	return fmt.Sprintf("\n %s %s%s\n\n", m.spinner.View(), m.status, tokenBadge)
 }
 
-func runAIWorkflow(diff string, contextStr string) (string, bool) {
-	cfg, err := config.LoadConfig()
+// workflowPrompts builds the prompts runAIWorkflow sends for task and
+// diff, and returns them with the template the message is rendered from.
+func workflowPrompts(cfg *config.Config, task string, diff string) (*prompt.Set, string) {
+	prompts := promptSet(cfg)
+	setDiffFields(prompts, diff)
+	if task == "pr" {
+		// Providers render the commit slot, so point it at the PR template
+		prompts.Templates[prompt.Commit] = prompts.Template(prompt.PR, "")
+	}
+	template := prompts.Template(prompt.Commit, cfg.CommitPromptTemplate)
+	prompts.Templates[prompt.Commit] = template
+	return prompts, template
+}
+
+// runAIWorkflow generates and reviews a message for diff with the provider
+// routed to task ("commit", "amend" or "pr") and the task's prompt.
+func runAIWorkflow(task string, diff string, contextStr string) (string, bool) {
+	res, err := resolveConfig()
 	if err != nil {
 		fmt.Println(styleError.Render(fmt.Sprintf("Config Error: %v", err)))
 		return "", false
 	}
+	cfg := res.Config
 
-	root, _ := git.GetRepoRoot()
-	repoCfg, _ := config.LoadRepoConfig(root)
-
-	selectedProvider := cfg.DefaultProvider
-	if repoCfg != nil && repoCfg.EnabledProvider != "" {
-		selectedProvider = repoCfg.EnabledProvider
-	}
-	if selectedProvider == "" {
-		fmt.Println(styleError.Render("No AI provider configured."))
+	p, model, err := selectProvider(cfg, task)
+	if err != nil {
+		fmt.Println(styleError.Render(err.Error()))
 		return "", false
 	}
 
-	pCfg, ok := cfg.Providers[selectedProvider]
-	if !ok {
-		fmt.Println(styleError.Render("Provider not configured."))
-		return "", false
+	rules := commitRules(cfg)
+	style := rules.Style
+	// The JSON schema describes a commit message, not a PR
+	structured := cfg.Output.Structured && task != "pr"
+	if structured {
+		p = &provider.StructuredProvider{
+			Provider:     p,
+			SystemPrompt: cfg.SystemPrompt,
+			Rules:        rules,
+			Render:       func(m provider.CommitMessage) string { return m.Render(style) },
+		}
 	}
 
-	model := pCfg.DefaultModel
-	if repoCfg != nil && repoCfg.ModelOverride != "" {
-		model = repoCfg.ModelOverride
-	}
+	prompts, template := workflowPrompts(cfg, task, diff)
 
-	factory := &provider.ProviderFactory{}
-	p := factory.GetProvider(selectedProvider, pCfg, model, cfg.SystemPrompt, cfg.CommitPromptTemplate)
-	if p == nil {
-		fmt.Println(styleError.Render("Failed to init provider."))
-		return "", false
+	// Commit messages follow the commit style; a PR only takes the language
+	rulesContext := "\n" + rules.Instructions() + "\n"
+	if task == "pr" {
+		rulesContext = ""
+		if rules.Language != "" {
+			rulesContext = fmt.Sprintf("\nWrite the title and description in %s.\n", rules.Language)
+		}
 	}
 
-	m := initialAISpinner(p, diff, contextStr)
-	pProgram := tea.NewProgram(m)
-	finalModel, err := pProgram.Run()
-	if err != nil {
-		fmt.Println(styleError.Render(fmt.Sprintf("Error: %v", err)))
-		return "", false
-	}
-	finalState := finalModel.(aiSpinnerModel)
-	if finalState.err != nil {
-		fmt.Println(styleError.Render(fmt.Sprintf("AI Error: %v", finalState.err)))
-		return "", false
-	}
+	n := candidateCount(cfg)
+	respCache := responseCache(cfg)
+	var instruction string
+	var title, description string
+	regenerate := true
+	firstRun := true
 
-	generatedMsg := finalState.msgResult
+	for {
+		if regenerate {
+			genContext := contextStr + rulesContext
+			if instruction != "" {
+				genContext += fmt.Sprintf("\nAdditional instructions from the user: %s\n", instruction)
+			}
+			// Only the first generation may come from the cache; regenerating means the user wants something new
+			key := responseKey(p, model, cfg.SystemPrompt, template, prompts.Data.Language, genContext, diff, strconv.Itoa(n), strconv.FormatBool(structured), style, strconv.Itoa(rules.MaxSubject), strings.Join(rules.Types, ","))
+			var candidates []string
+			cached := false
+			if respCache != nil && firstRun {
+				candidates, cached = respCache.Get(key)
+			}
+			firstRun = false
+			if cached {
+				fmt.Println(styleSubtle.Render("Using cached response (pass --no-cache to regenerate)."))
+			} else {
+				var ok bool
+				generate := newGenerateFunc(p, model, diff, genContext, n, prompts)
+				if task != "pr" {
+					generate = withRules(generate, p, rules, prompts)
+				}
+				candidates, ok = generateWithSpinner(generate, estimateTokens(diff+genContext))
+				if !ok {
+					return "", false
+				}
+				reportFallback(p)
+				if respCache != nil {
+					if err := respCache.Put(key, candidates); err != nil {
+						fmt.Println(styleSubtle.Render(fmt.Sprintf("Could not cache the response: %v", err)))
+						respCache = nil
+					}
+				}
+			}
 
-	// Review Loop
-	parts := strings.SplitN(generatedMsg, "\n", 2)
-	title := strings.TrimSpace(parts[0])
-	description := ""
-	if len(parts) > 1 {
-		description = strings.TrimSpace(parts[1])
-	}
+			msg, next := chooseCandidate(candidates)
+			switch next {
+			case "cancel":
+				return "", false
+			case "regenerate":
+				instruction = askInstruction(instruction)
+				continue
+			}
+			title, description = splitMessage(msg)
+			regenerate = false
+		}
 
-	for {
 		width := 70
 		labelStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("212")).MarginBottom(0)
 		contentStyle := lipgloss.NewStyle().PaddingLeft(2).Width(width).MaxWidth(width)
@@ -678,6 +805,7 @@ func runAIWorkflow(diff string, contextStr string) (string, bool) {
 						huh.NewOption("Confirm", "commit").Selected(true),
 						huh.NewOption("Edit", "edit"),
 						huh.NewOption("Edit in Editor", "editor"),
+						huh.NewOption("Regenerate with instruction", "regenerate"),
 						huh.NewOption("Cancel", "cancel"),
 					).
 					Value(&action),
@@ -709,19 +837,87 @@ func runAIWorkflow(diff string, contextStr string) (string, bool) {
 			if err != nil {
 				fmt.Println(styleError.Render(fmt.Sprintf("Editor error: %v", err)))
 			} else {
-				parts := strings.SplitN(newContent, "\n", 2)
-				title = strings.TrimSpace(parts[0])
-				description = ""
-				if len(parts) > 1 {
-					description = strings.TrimSpace(parts[1])
-				}
+				title, description = splitMessage(newContent)
 			}
 		}
+		if action == "regenerate" {
+			instruction = askInstruction(instruction)
+			regenerate = true
+		}
 	}
 
 	return fmt.Sprintf("%s\n\n%s", title, description), true
 }
 
+// generateWithSpinner runs generate behind the AI spinner, reporting errors itself.
+func generateWithSpinner(generate generateFunc, tokens int) ([]string, bool) {
+	m := initialAISpinner(generate, tokens)
+	defer m.cancel()
+	pProgram := tea.NewProgram(m)
+	finalModel, err := pProgram.Run()
+	if err != nil {
+		fmt.Println(styleError.Render(fmt.Sprintf("Error: %v", err)))
+		return nil, false
+	}
+	finalState := finalModel.(aiSpinnerModel)
+	if errors.Is(finalState.err, context.Canceled) {
+		return nil, false
+	}
+	if finalState.err != nil {
+		fmt.Println(styleError.Render(fmt.Sprintf("AI Error: %v", finalState.err)))
+		return nil, false
+	}
+	return finalState.results, true
+}
+
+func splitMessage(msg string) (string, string) {
+	parts := strings.SplitN(strings.TrimSpace(msg), "\n", 2)
+	title := strings.TrimSpace(parts[0])
+	description := ""
+	if len(parts) > 1 {
+		description = strings.TrimSpace(parts[1])
+	}
+	return title, description
+}
+
+// newGenerateFunc picks the generation strategy for a diff. If every hunk
+// fits the model's budget it goes out in a single request; otherwise,
+// even when just one hunk would be dropped, it is summarized piecewise
+// first. Providers that can't summarize get the packed diff instead.
+func newGenerateFunc(p provider.Provider, model string, diff string, contextStr string, n int, prompts *prompt.Set) generateFunc {
+	budget := provider.DiffBudget(model)
+	packed, complete := git.PackDiff(diff, budget)
+	if _, ok := p.(provider.Completer); complete || !ok {
+		return func(ctx context.Context, progress func(string)) ([]string, error) {
+			return provider.GenerateCandidates(prompt.With(ctx, prompts), p, packed, contextStr, n)
+		}
+	}
+
+	files := git.ParseDiff(diff)
+	groups := git.GroupDiff(files, budget)
+	return func(ctx context.Context, progress func(string)) ([]string, error) {
+		return provider.MapReduceCommitMessage(prompt.With(ctx, prompts), p, groups, git.DiffStat(files), contextStr, n, progress)
+	}
+}
+
+// withRules checks every candidate against the commit rules and has the
+// ones that break them fixed.
+func withRules(generate generateFunc, p provider.Provider, rules provider.CommitRules, prompts *prompt.Set) generateFunc {
+	return func(ctx context.Context, progress func(string)) ([]string, error) {
+		candidates, err := generate(ctx, progress)
+		if err != nil {
+			return nil, err
+		}
+		for i, c := range candidates {
+			if len(rules.Check(c)) > 0 {
+				progress("Fixing the message to match the commit style...")
+				candidates[i] = provider.EnforceRules(prompt.With(ctx, prompts), p, rules, c)
+			}
+		}
+		return candidates, nil
+	}
+}
+
 func handleCommit() {
 	fmt.Println(styleTitle.Render("AI Commit"))
 
@@ -792,7 +988,7 @@ func handleCommit() {
 	}
 	contextStr := contextBuilder.String()
 
-	finalMsg, ok := runAIWorkflow(diff, contextStr)
+	finalMsg, ok := runAIWorkflow("commit", diff, contextStr)
 	if !ok {
 		fmt.Println(styleSubtle.Render("Cancelled."))
 		return
@@ -842,7 +1038,7 @@ func handleAmend() {
 	}
 	contextStr := contextBuilder.String()
 
-	finalMsg, ok := runAIWorkflow(diff, contextStr)
+	finalMsg, ok := runAIWorkflow("amend", diff, contextStr)
 	if !ok {
 		fmt.Println(styleSubtle.Render("Cancelled."))
 		return
@@ -906,7 +1102,7 @@ func handleGenerate() {
 	}
 	contextStr := contextBuilder.String()
 
-	finalMsg, ok := runAIWorkflow(diff, contextStr)
+	finalMsg, ok := runAIWorkflow("commit", diff, contextStr)
 	if ok {
 		os.WriteFile(msgFile, []byte(finalMsg), 0644)
 	}
@@ -1053,7 +1249,7 @@ func handleSync() {
 }
 
 func handleInit() {
-	err := runSpinner("Initializing AI-Git...", func() error {
+	err := runSpinner("Initializing AI-Git...", func(ctx context.Context) error {
 		time.Sleep(500 * time.Millisecond) // UX pause
 		root, err := git.GetRepoRoot()
 		if err != nil {
@@ -1098,11 +1294,24 @@ func handleDoctor() {
 	}
 
 	// Config
-	cfg, err := config.LoadConfig()
+	res, err := resolveConfig()
+	var secretsErr error
+	if errors.Is(err, config.ErrSecretsLocked) {
+		// Everything but the stored keys can still be checked
+		secretsErr, err = err, nil
+	}
 	if err != nil {
 		check("Config", false, err.Error())
 	} else {
+		cfg := res.Config
 		check("Config", true, "Loaded")
+		if secretsErr != nil {
+			check("Secrets", false, secretsErr.Error())
+		} else if plain, err := config.PlaintextSecrets(); err == nil && len(plain) > 0 {
+			check("Secrets", false, fmt.Sprintf("%d stored in plaintext, run `ai-git secrets migrate`", len(plain)))
+		} else if mode, _ := config.SecretsMode(); mode != "" {
+			check("Secrets", true, "Encrypted ("+mode+")")
+		}
 		if cfg.DefaultProvider == "" {
 			check("Provider", false, "No default set")
 		} else {
@@ -1110,16 +1319,71 @@ func handleDoctor() {
 			pCfg, ok := cfg.Providers[cfg.DefaultProvider]
 			if !ok {
 				check("Setup", false, "Provider config missing")
-			} else if pCfg.APIKey == "" && cfg.DefaultProvider != "ollama" {
+			} else if kind := pCfg.Kind(cfg.DefaultProvider); kind == "fake" || kind == "replay" || pCfg.Cassette != "" {
+				check("Auth", true, "Offline provider, no key needed")
+			} else if kind == "exec" {
+				p := (&provider.ProviderFactory{}).GetProvider(cfg.DefaultProvider, pCfg, pCfg.DefaultModel, "", "")
+				if err := provider.StartError(p); err != nil {
+					check("Plugin", false, err.Error())
+				} else {
+					check("Plugin", true, fmt.Sprintf("%s (%s)", pCfg.Command, strings.Join(p.(*provider.ExecProvider).Capabilities(), ", ")))
+				}
+			} else if key, err := pCfg.ResolveAPIKey(cfg.DefaultProvider); err != nil {
+				check("Auth", false, err.Error())
+			} else if key == "" && secretsErr != nil && kind != "ollama" {
+				check("Auth", false, "API Key unavailable while secrets are locked")
+			} else if key == "" && kind != "ollama" && pCfg.BaseURL == "" {
 				check("Auth", false, "API Key missing")
+			} else if key != "" && (pCfg.APIKey == "" || config.IsSecretRef(pCfg.APIKey)) {
+				check("Auth", true, "API Key resolved from environment or command")
 			} else {
 				check("Auth", true, "API Key set")
 			}
+
+			if ok {
+				models, err := discoverModels(context.Background(), cfg.DefaultProvider, pCfg, false)
+				switch {
+				case err != nil:
+					check("Model", false, fmt.Sprintf("Could not list models: %v", err))
+				case pCfg.DefaultModel == "":
+					check("Model", false, "No default model set")
+				case !isKnownModel(models, pCfg, pCfg.DefaultModel):
+					check("Model", false, fmt.Sprintf("'%s' is not offered by %s", pCfg.DefaultModel, cfg.DefaultProvider))
+				default:
+					check("Model", true, pCfg.DefaultModel)
+				}
+			}
+		}
+
+		if problems := promptProblems(res.Global, res.Repo); len(problems) > 0 {
+			check("Prompts", false, strings.Join(problems, "; "))
+		} else {
+			check("Prompts", true, "Valid")
+		}
+		if style := commitStyle(cfg); !slices.Contains(provider.CommitStyles, style) {
+			check("Style", false, fmt.Sprintf("Unknown commit style '%s' (use %s)", style, strings.Join(provider.CommitStyles, ", ")))
+		} else {
+			check("Style", true, style)
+		}
+		if problems := taskProblems(res.Global, res.Repo); len(problems) > 0 {
+			check("Tasks", false, strings.Join(problems, "; "))
+		} else if len(cfg.Tasks) > 0 {
+			check("Tasks", true, "Valid")
 		}
 	}
 }
 
 func handleConfig() {
+	if len(os.Args) > 2 {
+		switch os.Args[2] {
+		case "show":
+			handleConfigShow(os.Args[3:])
+			return
+		case "get", "set", "unset", "list":
+			handleConfigCommand(os.Args[2], os.Args[3:])
+			return
+		}
+	}
 	// If CLI args present, legacy mode
 	if len(os.Args) > 2 {
 		legacyConfig()
@@ -1138,18 +1402,34 @@ func handleConfig() {
 	var provider string
 	var apiKey string
 	var model string
+	var embeddingModel string
+	var baseURL string
 
 	// 1. Select Provider
+	providerOptions := []huh.Option[string]{
+		huh.NewOption("OpenAI", "openai"),
+		huh.NewOption("Gemini", "gemini"),
+		huh.NewOption("Anthropic", "anthropic"),
+		huh.NewOption("Ollama", "ollama"),
+	}
+	// Named entries (e.g. a vLLM or OpenRouter endpoint) registered earlier
+	var named []string
+	for name, pc := range cfg.Providers {
+		if pc.Kind(name) != name {
+			named = append(named, name)
+		}
+	}
+	sort.Strings(named)
+	for _, name := range named {
+		providerOptions = append(providerOptions, huh.NewOption(fmt.Sprintf("%s (%s)", name, cfg.Providers[name].Kind(name)), name))
+	}
+	providerOptions = append(providerOptions, huh.NewOption("Add OpenAI-compatible endpoint...", "+compatible"))
+
 	form := huh.NewForm(
 		huh.NewGroup(
 			huh.NewSelect[string]().
 				Title("Default Provider").
-				Options(
-					huh.NewOption("OpenAI", "openai"),
-					huh.NewOption("Gemini", "gemini"),
-					huh.NewOption("Anthropic", "anthropic"),
-					huh.NewOption("Ollama", "ollama"),
-				).
+				Options(providerOptions...).
 				Value(&provider),
 		),
 	)
@@ -1158,40 +1438,132 @@ func handleConfig() {
 		return
 	}
 
+	var pCfg config.ProviderConfig
+	if provider == "+compatible" {
+		nameForm := huh.NewForm(
+			huh.NewGroup(
+				huh.NewInput().
+					Title("Name for this provider").
+					Placeholder("e.g. openrouter, vllm, azure").
+					Value(&provider),
+			),
+		)
+		if err := nameForm.Run(); err != nil {
+			return
+		}
+		provider = strings.TrimSpace(provider)
+		if provider == "" || provider == "+compatible" {
+			fmt.Println(styleError.Render("Provider name cannot be empty."))
+			return
+		}
+		pCfg = cfg.Providers[provider]
+		pCfg.Type = "openai"
+	} else {
+		pCfg = cfg.Providers[provider]
+	}
+
 	// Load existing values
-	pCfg := cfg.Providers[provider]
+	kind := pCfg.Kind(provider)
 	apiKey = pCfg.APIKey
 	model = pCfg.DefaultModel
+	embeddingModel = pCfg.EmbeddingModel
+	baseURL = pCfg.BaseURL
 
-	// 2. Configure Details
-	// Use different fields depending on provider
-	inputs := []huh.Field{
-		huh.NewInput().
-			Title("Default Model").
-			Value(&model),
-	}
-
-	if provider != "ollama" {
-		inputs = append([]huh.Field{
+	// 2. Credentials and endpoint, needed before models can be listed
+	var inputs []huh.Field
+	if kind != "ollama" {
+		inputs = append(inputs,
 			huh.NewInput().
 				Title("API Key").
 				Value(&apiKey).
 				Password(true),
-		}, inputs...)
+		)
+	}
+	if kind == "openai" || kind == "ollama" {
+		inputs = append(inputs,
+			huh.NewInput().
+				Title("Base URL").
+				Placeholder("leave empty for the official endpoint").
+				Value(&baseURL),
+		)
+	}
+	if len(inputs) > 0 {
+		if err := huh.NewForm(huh.NewGroup(inputs...)).Run(); err != nil {
+			return
+		}
+	}
+	pCfg.APIKey = apiKey
+	pCfg.BaseURL = baseURL
+
+	// 3. Pick a model from what the provider offers, plus custom_models
+	var known []string
+	err = runSpinner("Fetching available models...", func(ctx context.Context) error {
+		var e error
+		known, e = discoverModels(ctx, provider, pCfg, true)
+		return e
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Println(styleSubtle.Render(fmt.Sprintf("Could not list models (%v); showing defaults.", err)))
+	}
+	if len(known) == 0 {
+		known = defaultModels(kind)
 	}
 
-	formDetails := huh.NewForm(
-		huh.NewGroup(inputs...),
-	)
+	const customModel = "+custom"
+	var modelOptions []huh.Option[string]
+	for _, m := range mergeModels(known, pCfg.CustomModels) {
+		modelOptions = append(modelOptions, huh.NewOption(m, m).Selected(m == model))
+	}
+	modelOptions = append(modelOptions, huh.NewOption("Enter a model name...", customModel))
 
-	if err := formDetails.Run(); err != nil {
+	choice := model
+	if err := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title("Default Model").
+				Options(modelOptions...).
+				Height(12).
+				Value(&choice),
+		),
+	).Run(); err != nil {
 		return
 	}
+	if choice == customModel {
+		if err := huh.NewForm(
+			huh.NewGroup(
+				huh.NewInput().
+					Title("Model name").
+					Value(&model),
+			),
+		).Run(); err != nil {
+			return
+		}
+		model = strings.TrimSpace(model)
+		// Remember hand-typed models so they are offered and accepted next time
+		if model != "" && !isKnownModel(known, pCfg, model) {
+			pCfg.CustomModels = append(pCfg.CustomModels, model)
+		}
+	} else {
+		model = choice
+	}
+
+	if kind != "anthropic" {
+		if err := huh.NewForm(
+			huh.NewGroup(
+				huh.NewInput().
+					Title("Embedding Model (for index/chat)").
+					Placeholder("leave empty for provider default").
+					Value(&embeddingModel),
+			),
+		).Run(); err != nil {
+			return
+		}
+	}
 
 	// Save
 	cfg.DefaultProvider = provider
-	pCfg.APIKey = apiKey
 	pCfg.DefaultModel = model
+	pCfg.EmbeddingModel = embeddingModel
 	if cfg.Providers == nil {
 		cfg.Providers = make(map[string]config.ProviderConfig)
 	}
@@ -1204,33 +1576,22 @@ func handleConfig() {
 	}
 }
 
+// legacyConfig keeps the original shortcuts working on top of `config set`.
 func legacyConfig() {
-	cfg, _ := config.LoadConfig()
-	subCommand := os.Args[2]
-	switch subCommand {
-	case "set-provider":
-		if len(os.Args) < 4 {
-			return
-		}
-		cfg.DefaultProvider = os.Args[3]
-	case "set-key":
-		if len(os.Args) < 5 {
-			return
-		}
-		pName := os.Args[3]
-		pCfg := cfg.Providers[pName]
-		pCfg.APIKey = os.Args[4]
-		cfg.Providers[pName] = pCfg
-	case "set-model":
-		if len(os.Args) < 5 {
-			return
-		}
-		pName := os.Args[3]
-		pCfg := cfg.Providers[pName]
-		pCfg.DefaultModel = os.Args[4]
-		cfg.Providers[pName] = pCfg
+	args := os.Args[3:]
+	switch {
+	case os.Args[2] == "set-provider" && len(args) == 1:
+		handleConfigCommand("set", []string{"default_provider", args[0]})
+	case os.Args[2] == "set-key" && len(args) == 2:
+		handleConfigCommand("set", []string{"providers." + args[0] + ".api_key", args[1]})
+	case os.Args[2] == "set-model" && len(args) == 2:
+		handleConfigCommand("set", []string{"providers." + args[0] + ".default_model", args[1]})
+	default:
+		fmt.Fprintln(os.Stderr, configUsage)
+		fmt.Fprintln(os.Stderr, "       ai-git config show [--origin] [key...]")
+		fmt.Fprintln(os.Stderr, "       ai-git config set-provider <name> | set-key <provider> <key> | set-model <provider> <model>")
+		os.Exit(1)
 	}
-	cfg.Save()
 }
 
 func parseGitStatusFiles(status string) []string {
@@ -1250,7 +1611,7 @@ func parseGitStatusFiles(status string) []string {
 }
 
 func estimateTokens(text string) int {
-	return len(text) / 4
+	return git.EstimateTokens(text)
 }
 
 func openInEditor(initialContent string) (string, error) {
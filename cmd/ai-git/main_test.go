@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/eliau2005/ai-git/internal/prompt"
+	"github.com/eliau2005/ai-git/internal/provider"
+)
+
+func TestNewGenerateFunc(t *testing.T) {
+	const model = "generate-test-model"
+	provider.RegisterContextWindow(model, 1000) // the minimum budget, 1024 tokens
+
+	small := "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-a\n+b\n"
+	// One hunk over the budget: PackDiff would drop it and report the diff incomplete
+	oversized := small + "diff --git a/big.txt b/big.txt\n--- a/big.txt\n+++ b/big.txt\n@@ -1 +1,300 @@\n" +
+		strings.Repeat("+a line of the generated file\n", 300)
+
+	tests := []struct {
+		name      string
+		diff      string
+		wantCalls int // 1 for a single request, more when summarized first
+	}{
+		{name: "fits", diff: small, wantCalls: 1},
+		{name: "oversized hunk", diff: oversized, wantCalls: 3}, // two groups, then the message
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &provider.FakeProvider{Responses: []string{"fix: update a"}}
+			prompts := &prompt.Set{Templates: map[string]string{}}
+			generate := newGenerateFunc(p, model, tt.diff, "", 1, prompts)
+			candidates, err := generate(context.Background(), func(string) {})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(candidates) != 1 || candidates[0] != "fix: update a" {
+				t.Errorf("candidates = %q", candidates)
+			}
+			calls := p.Prompts()
+			if len(calls) != tt.wantCalls {
+				t.Fatalf("made %d calls, want %d", len(calls), tt.wantCalls)
+			}
+			if tt.wantCalls == 1 && !strings.Contains(calls[0], "+b") {
+				t.Errorf("single request lacks the diff:\n%s", calls[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/eliau2005/ai-git/internal/config"
+	"github.com/eliau2005/ai-git/internal/provider"
+)
+
+func handleModels(args []string) {
+	fmt.Println(styleTitle.Render("Available Models"))
+
+	res, err := resolveConfig()
+	if err != nil {
+		fmt.Println(styleError.Render(fmt.Sprintf("Config Error: %v", err)))
+		return
+	}
+	cfg := res.Config
+
+	refresh := false
+	var names []string
+	for _, arg := range args {
+		if arg == "--refresh" {
+			refresh = true
+		} else {
+			names = append(names, arg)
+		}
+	}
+	if len(names) == 0 {
+		for name := range cfg.Providers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	if len(names) == 0 {
+		fmt.Println(styleSubtle.Render("No providers configured. Run 'ai-git config' first."))
+		return
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	for _, name := range names {
+		pCfg, ok := cfg.Providers[name]
+		if !ok {
+			fmt.Println(styleError.Render(fmt.Sprintf("Provider '%s' is not configured.", name)))
+			continue
+		}
+
+		fmt.Println()
+		fmt.Println(styleSuccess.Render(name))
+		known, err := discoverModels(ctx, name, pCfg, refresh)
+		if err != nil {
+			fmt.Println(styleError.Render(fmt.Sprintf("  Could not list models: %v", err)))
+		}
+
+		for _, m := range mergeModels(known, pCfg.CustomModels) {
+			line := "  " + m
+			if provider.HasModel([]string{m}, pCfg.DefaultModel) {
+				line = "* " + m
+			}
+			if !provider.HasModel(known, m) {
+				line += styleSubtle.Render(" (custom)")
+			}
+			fmt.Println(line)
+		}
+
+		if err == nil && pCfg.DefaultModel != "" && !isKnownModel(known, pCfg, pCfg.DefaultModel) {
+			// Name the setting the model came from, e.g. the repo's model_override
+			field := "default_model"
+			if s, ok := res.Origin("providers." + name + ".default_model"); ok && s.Layer != config.LayerGlobal {
+				field += " (" + s.Layer + ")"
+			}
+			fmt.Println(styleError.Render(fmt.Sprintf("  %s '%s' is not offered by %s.", field, pCfg.DefaultModel, name)))
+		}
+	}
+}
+
+// discoverModels lists the models a configured provider offers, cached for a day.
+func discoverModels(ctx context.Context, name string, pCfg config.ProviderConfig, refresh bool) ([]string, error) {
+	factory := &provider.ProviderFactory{}
+	p := factory.GetProvider(name, pCfg, pCfg.DefaultModel, "", "")
+	if p == nil {
+		return nil, fmt.Errorf("unknown provider type '%s'", pCfg.Kind(name))
+	}
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	return provider.DiscoverModels(ctx, p, modelCacheKey(name, pCfg), refresh)
+}
+
+func modelCacheKey(name string, pCfg config.ProviderConfig) string {
+	return name + "|" + pCfg.BaseURL
+}
+
+// isKnownModel accepts models the provider lists plus the user's custom_models.
+func isKnownModel(known []string, pCfg config.ProviderConfig, model string) bool {
+	return provider.HasModel(known, model) || slices.Contains(pCfg.CustomModels, model)
+}
+
+// warnedModels are the provider/model pairs already warned about this run,
+// so commands that select several routes, like chat, warn once each.
+var warnedModels = make(map[string]bool)
+
+// warnUnknownModel flags a model missing from the provider's cached model
+// list. It never touches the network, so it is cheap enough for every run.
+func warnUnknownModel(name string, pCfg config.ProviderConfig, model string) {
+	known, ok := provider.CachedModels(modelCacheKey(name, pCfg))
+	if !ok || model == "" || isKnownModel(known, pCfg, model) || warnedModels[name+"/"+model] {
+		return
+	}
+	warnedModels[name+"/"+model] = true
+	fmt.Println(styleError.Render(fmt.Sprintf("Warning: model '%s' is not offered by %s (see 'ai-git models %s').", model, name, name)))
+}
+
+func mergeModels(known []string, custom []string) []string {
+	out := append([]string(nil), known...)
+	for _, m := range custom {
+		if !slices.Contains(out, m) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// defaultModels is the built-in list for a provider kind, used when discovery fails.
+func defaultModels(kind string) []string {
+	return provider.DefaultModels[kind]
+}
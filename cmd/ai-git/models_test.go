@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captureStdout returns what fn prints.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	fn()
+	w.Close()
+	return <-done
+}
+
+func TestWarnUnknownModelPerRoute(t *testing.T) {
+	r := newTestRepo(t)
+	cacheHome := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cacheHome)
+	clear(warnedModels)
+	t.Cleanup(func() { clear(warnedModels) })
+
+	models, _ := json.Marshal(map[string]any{
+		"local|http://localhost:11434": map[string]any{"fetched": time.Now(), "models": []string{"llama3", "nomic-embed-text"}},
+	})
+	r.write(filepath.Join(cacheHome, "ai-git", "models.json"), string(models))
+
+	tests := []struct {
+		name   string
+		config string
+		warns  []string
+	}{
+		{
+			name:   "known models",
+			config: "    default_model: llama3\n    embedding_model: nomic-embed-text\n",
+		},
+		{
+			name:   "unknown chat model",
+			config: "    default_model: llama9\n    embedding_model: nomic-embed-text\n",
+			warns:  []string{"'llama9'"},
+		},
+		{
+			name:   "unknown embedding model",
+			config: "    default_model: llama3\n    embedding_model: embed-9000\n",
+			warns:  []string{"'embed-9000'"},
+		},
+		{
+			name:   "both unknown",
+			config: "    default_model: llama9\n    embedding_model: embed-9000\n",
+			warns:  []string{"'llama9'", "'embed-9000'"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clear(warnedModels)
+			cfg := r.config("default_provider: local\nproviders:\n  local:\n    type: ollama\n    base_url: http://localhost:11434\n" + tt.config)
+			out := captureStdout(t, func() {
+				// What chat does: the chat route, then the embed route
+				for _, task := range []string{"chat", "embed", "chat"} {
+					if _, _, err := selectProvider(cfg, task); err != nil {
+						t.Error(err)
+					}
+				}
+			})
+			if got := strings.Count(out, "Warning:"); got != len(tt.warns) {
+				t.Errorf("%d warnings, want %d:\n%s", got, len(tt.warns), out)
+			}
+			for _, w := range tt.warns {
+				if strings.Count(out, w) != 1 {
+					t.Errorf("want one warning about %s:\n%s", w, out)
+				}
+			}
+		})
+	}
+}
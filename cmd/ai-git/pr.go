@@ -7,7 +7,6 @@ import (
 	"strings"
 
 	"github.com/charmbracelet/huh"
-	"github.com/eliau2005/ai-git/internal/config"
 	"github.com/eliau2005/ai-git/internal/git"
 	"github.com/eliau2005/ai-git/internal/github"
 )
@@ -30,7 +29,7 @@ func handlePR() {
 func handlePRCreate() {
 	fmt.Println(styleTitle.Render("Create Pull Request"))
 
-	cfg, err := config.LoadConfig()
+	cfg, err := resolveConfig()
 	if err != nil {
 		fmt.Println(styleError.Render(fmt.Sprintf("Config error: %v", err)))
 		return
@@ -47,9 +46,13 @@ func handlePRCreate() {
 		return
 	}
 
-	platformCfg, ok := cfg.Platforms[remoteInfo.Platform]
-	if !ok || platformCfg.Token == "" {
-		fmt.Println(styleError.Render("No token found for GitHub. Please run 'ai-git auth' first."))
+	token, err := cfg.Platforms[remoteInfo.Platform].ResolveToken(remoteInfo.Platform)
+	if err != nil {
+		fmt.Println(styleError.Render(fmt.Sprintf("Failed to resolve GitHub token: %v", err)))
+		return
+	}
+	if token == "" {
+		fmt.Println(styleError.Render("No token found for GitHub. Please run 'ai-git auth' or set GITHUB_TOKEN."))
 		return
 	}
 
@@ -90,10 +93,9 @@ func handlePRCreate() {
 	}
 
 	// AI Generate PR Content
-	// Temporarily repurpose the runAIWorkflow to generate PR description
-	contextStr := fmt.Sprintf("Generate a Pull Request Title and Description for these changes. Base: %s, Head: %s.", baseBranch, currentBranch)
-	
-	finalMsg, ok := runAIWorkflow(diff, contextStr)
+	contextStr := fmt.Sprintf("Base: %s, Head: %s.", baseBranch, currentBranch)
+
+	finalMsg, ok := runAIWorkflow("pr", diff, contextStr)
 	if !ok {
 		fmt.Println(styleSubtle.Render("Cancelled."))
 		return
@@ -121,9 +123,9 @@ func handlePRCreate() {
 	}
 
 	// Create PR via GitHub API
-	err = runSpinner("Creating PR...", func() error {
-		client := github.NewClient(platformCfg.Token)
-		_, err := client.CreatePullRequest(context.Background(), remoteInfo.Owner, remoteInfo.Repo, title, body, currentBranch, baseBranch)
+	err = runSpinner("Creating PR...", func(ctx context.Context) error {
+		client := github.NewClient(token)
+		_, err := client.CreatePullRequest(ctx, remoteInfo.Owner, remoteInfo.Repo, title, body, currentBranch, baseBranch)
 		return err
 	})
 
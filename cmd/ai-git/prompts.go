@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/eliau2005/ai-git/internal/config"
+	"github.com/eliau2005/ai-git/internal/git"
+	"github.com/eliau2005/ai-git/internal/prompt"
+)
+
+// promptSet collects the prompt overrides of the effective config and the
+// fields every prompt of this run can use.
+func promptSet(cfg *config.Config) *prompt.Set {
+	set := &prompt.Set{Templates: make(map[string]string)}
+	if cfg == nil {
+		return set
+	}
+	for task, tmpl := range cfg.Prompts {
+		set.Templates[task] = tmpl
+	}
+	set.Data.Language = cfg.Output.Language
+	set.Data.Style = commitStyle(cfg)
+	set.Data.Branch, _ = git.GetCurrentBranch()
+	set.Data.RecentCommits, _ = git.GetRecentCommitMessages(5)
+	return set
+}
+
+// setDiffFields fills in the fields derived from the full diff, so they
+// stay accurate even when only a packed or summarized diff is sent.
+func setDiffFields(set *prompt.Set, diff string) {
+	files := git.ParseDiff(diff)
+	set.Data.Files = make([]string, 0, len(files))
+	for _, f := range files {
+		set.Data.Files = append(set.Data.Files, f.Path)
+	}
+	set.Data.Stats = git.DiffStat(files)
+}
+
+// promptProblems lists prompt overrides that name an unknown task or don't parse.
+func promptProblems(cfg *config.Config, repoCfg *config.RepoConfig) []string {
+	var problems []string
+	checkAll := func(where string, prompts map[string]string) {
+		tasks := make([]string, 0, len(prompts))
+		for task := range prompts {
+			tasks = append(tasks, task)
+		}
+		sort.Strings(tasks)
+		for _, task := range tasks {
+			if !slices.Contains(prompt.Tasks, task) {
+				problems = append(problems, fmt.Sprintf("%s: unknown task '%s'", where, task))
+			} else if err := prompt.Check(prompts[task]); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %s: %v", where, task, err))
+			}
+		}
+	}
+	checkAll("global", cfg.Prompts)
+	if cfg.CommitPromptTemplate != "" {
+		if err := prompt.Check(cfg.CommitPromptTemplate); err != nil {
+			problems = append(problems, fmt.Sprintf("global: commit_prompt_template: %v", err))
+		}
+	}
+	if repoCfg != nil {
+		checkAll("repo", repoCfg.Prompts)
+	}
+	return problems
+}
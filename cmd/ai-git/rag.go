@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io/fs"
 	"os"
@@ -12,6 +13,7 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/eliau2005/ai-git/internal/config"
 	"github.com/eliau2005/ai-git/internal/git"
+	"github.com/eliau2005/ai-git/internal/prompt"
 	"github.com/eliau2005/ai-git/internal/provider"
 	"github.com/eliau2005/ai-git/internal/rag"
 )
@@ -25,7 +27,17 @@ func handleIndex() {
 		return
 	}
 
-	p, ok := getActiveProvider().(provider.Embedder)
+	res, err := resolveConfig()
+	if err != nil {
+		fmt.Println(styleError.Render(fmt.Sprintf("Config Error: %v", err)))
+		return
+	}
+	activeProv, _, err := selectProvider(res.Config, "embed")
+	if err != nil {
+		fmt.Println(styleError.Render(err.Error()))
+		return
+	}
+	p, ok := activeProv.(provider.Embedder)
 	if !ok {
 		fmt.Println(styleError.Render("Current provider does not support embeddings."))
 		return
@@ -35,11 +47,18 @@ func handleIndex() {
 	store.Chunks = nil // Clear existing chunks for re-index
 
 	var count int
+	var delay time.Duration
 	rules, _ := git.LoadIgnoreRules(root)
 
-	fmt.Println(styleSubtle.Render("Scanning and embedding files... This may take a moment due to API rate limits."))
+	fmt.Println(styleSubtle.Render("Scanning and embedding files..."))
+
+	ctx, stop := interruptContext()
+	defer stop()
 
 	err = filepath.Walk(root, func(path string, info fs.FileInfo, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		if err != nil || info.IsDir() {
 			// Skip .git directory
 			if info != nil && info.IsDir() && info.Name() == ".git" {
@@ -70,7 +89,7 @@ func handleIndex() {
 		}
 
 		fmt.Printf("Indexing %s... ", relPath)
-		emb, err := p.GenerateEmbedding(text)
+		emb, err := embedThrottled(ctx, p, text, &delay)
 		if err == nil && len(emb) > 0 {
 			store.AddChunk(rag.Chunk{
 				ID:        relPath,
@@ -80,7 +99,6 @@ func handleIndex() {
 			})
 			count++
 			fmt.Println("Done")
-			time.Sleep(2 * time.Second) // Rate limit prevention
 		} else {
 			fmt.Println("Failed:", err)
 		}
@@ -92,10 +110,41 @@ func handleIndex() {
 		return
 	}
 
+	// A fallback that stood in embedded every file, so record its model
+	store.Model = embeddingModel(res.Config, servedBy(activeProv))
 	store.Save(root)
+	reportFallback(activeProv)
 	fmt.Println(styleSuccess.Render(fmt.Sprintf("Successfully indexed %d files.", count)))
 }
 
+// embedThrottled runs at full speed until the provider reports a rate
+// limit, then paces requests by *delay, easing off again on success.
+func embedThrottled(ctx context.Context, p provider.Embedder, text string, delay *time.Duration) ([]float32, error) {
+	const maxDelay = 30 * time.Second
+	for attempt := 0; ; attempt++ {
+		if *delay > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(*delay):
+			}
+		}
+
+		emb, err := p.GenerateEmbedding(ctx, text)
+		rl, limited := provider.IsRateLimit(err)
+		if !limited || attempt >= 3 {
+			if err == nil {
+				*delay /= 2
+			}
+			return emb, err
+		}
+
+		*delay = max(*delay*2, rl.RetryAfter, time.Second)
+		*delay = min(*delay, maxDelay)
+		fmt.Printf("(rate limited, waiting %s) ", delay.Round(time.Second))
+	}
+}
+
 func handleChat() {
 	fmt.Println(styleTitle.Render("Chat with your Repository"))
 
@@ -105,9 +154,24 @@ func handleChat() {
 		return
 	}
 
-	activeProv := getActiveProvider()
+	res, err := resolveConfig()
+	if err != nil {
+		fmt.Println(styleError.Render(fmt.Sprintf("Config Error: %v", err)))
+		return
+	}
+	activeProv, model, err := selectProvider(res.Config, "chat")
+	if err != nil {
+		fmt.Println(styleError.Render(err.Error()))
+		return
+	}
+	// Queries must be embedded the way `ai-git index` embedded the files
+	embedProv, _, err := selectProvider(res.Config, "embed")
+	if err != nil {
+		fmt.Println(styleError.Render(err.Error()))
+		return
+	}
 	chatter, okChat := activeProv.(provider.Chatter)
-	embedder, okEmbed := activeProv.(provider.Embedder)
+	embedder, okEmbed := embedProv.(provider.Embedder)
 
 	if !okChat || !okEmbed {
 		fmt.Println(styleError.Render("Current provider does not fully support Chat and Embeddings."))
@@ -119,11 +183,23 @@ func handleChat() {
 		fmt.Println(styleError.Render("No index found. Please run 'ai-git index' first."))
 		return
 	}
+	if store.Model == "" {
+		fmt.Println(styleSubtle.Render("The index doesn't record its embedding model; re-run 'ai-git index' if answers seem unrelated."))
+	} else if e, ok := indexEmbedder(res.Config, embedProv, store.Model); ok {
+		embedder = e
+	} else {
+		fmt.Println(styleError.Render(fmt.Sprintf("The index was built with %s, but embeddings now use %s. Please run 'ai-git index' again.", store.Model, embeddingModel(res.Config, ""))))
+		return
+	}
 
 	scanner := bufio.NewScanner(os.Stdin)
 	userStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("39")).Bold(true)
 	aiStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("212"))
 
+	// The conversation so far, alternating user and assistant messages
+	var history []provider.Message
+	var lastQuery string
+
 	for {
 		fmt.Print(userStyle.Render("\nYou: "))
 		if !scanner.Scan() {
@@ -137,8 +213,12 @@ func handleChat() {
 			continue
 		}
 
-		queryEmb, err := embedder.GenerateEmbedding(query)
+		// Ctrl+C aborts the current answer but keeps the chat session open
+		ctx, stop := interruptContext()
+		// Follow-ups like "where is that called?" need the previous question to find anything
+		queryEmb, err := embedder.GenerateEmbedding(ctx, strings.TrimSpace(lastQuery+"\n"+query))
 		if err != nil {
+			stop()
 			fmt.Println(styleError.Render(fmt.Sprintf("Failed to embed query: %v", err)))
 			continue
 		}
@@ -148,35 +228,183 @@ func handleChat() {
 		for _, r := range results {
 			contextBuilder.WriteString(fmt.Sprintf("--- File: %s ---\n%s\n", r.Chunk.FilePath, r.Chunk.Content))
 		}
+		ragContext := contextBuilder.String()
 
+		history = append(history, provider.Message{Role: "user", Content: query})
+		if c, ok := activeProv.(provider.Completer); ok {
+			// Half the window for the conversation, the rest for retrieved code and the reply
+			budget := max(provider.ContextWindow(model)/2-estimateTokens(ragContext), 1024)
+			if provider.HistoryTokens(history) > budget {
+				fmt.Println(styleSubtle.Render("Summarizing earlier messages..."))
+				if compacted, err := provider.CompactHistory(ctx, c, history, budget); err == nil {
+					history = compacted
+				} else if ctx.Err() == nil {
+					fmt.Println(styleError.Render(fmt.Sprintf("Failed to summarize history: %v", err)))
+				}
+			}
+		}
+
+		var answer strings.Builder
 		fmt.Print(aiStyle.Render("\nAI: "))
-		err = chatter.AskChatStream(query, contextBuilder.String(), func(chunk string) {
+		err = chatter.AskChatStream(ctx, history, ragContext, func(chunk string) {
 			fmt.Print(chunk)
+			answer.WriteString(chunk)
 		})
+		interrupted := ctx.Err() != nil
+		stop()
 		fmt.Println()
 
-		if err != nil {
+		if interrupted || err != nil {
+			// Forget the unanswered question so roles keep alternating
+			history = history[:len(history)-1]
+		} else {
+			history = append(history, provider.Message{Role: "assistant", Content: answer.String()})
+			lastQuery = query
+		}
+
+		if interrupted {
+			fmt.Println(styleSubtle.Render("(interrupted)"))
+		} else if err != nil {
 			fmt.Println(styleError.Render(fmt.Sprintf("\nError: %v", err)))
+		} else {
+			reportFallback(activeProv)
+		}
+	}
+}
+
+// getActiveProvider returns the provider routed to task, one of
+// config.TaskNames, and the prompts for the command, built from the same
+// config.
+func getActiveProvider(task string) (provider.Provider, *prompt.Set) {
+	res, err := resolveConfig()
+	if err != nil {
+		fmt.Println(styleError.Render(fmt.Sprintf("Config Error: %v", err)))
+		return nil, promptSet(nil)
+	}
+
+	p, _, err := selectProvider(res.Config, task)
+	if err != nil {
+		fmt.Println(styleError.Render(err.Error()))
+		return nil, promptSet(res.Config)
+	}
+	return p, promptSet(res.Config)
+}
+
+// embeddingModel names the provider entry and the model it embeds with,
+// e.g. "openai/text-embedding-3-small", to tell whether an index still
+// matches. An empty name means the embed route's provider.
+func embeddingModel(cfg *config.Config, name string) string {
+	route := cfg.Tasks["embed"]
+	primary := route.Provider
+	if primary == "" {
+		primary = cfg.DefaultProvider
+	}
+	if name == "" {
+		name = primary
+	}
+	model := cfg.Providers[name].EmbeddingModel
+	if name == primary && route.Model != "" {
+		// Fallbacks keep their own models
+		model = route.Model
+	}
+	if model == "" {
+		model = "default"
+	}
+	return name + "/" + model
+}
+
+// servedBy names the provider entry that answered p's requests: the
+// fallback that stood in, if any.
+func servedBy(p provider.Provider) string {
+	if chain, ok := p.(*provider.FallbackProvider); ok {
+		if used, _ := chain.Used(); used != "" {
+			return used
 		}
 	}
+	return p.GetName()
 }
 
-func getActiveProvider() provider.Provider {
-	cfg, _ := config.LoadConfig()
-	root, _ := git.GetRepoRoot()
-	repoCfg, _ := config.LoadRepoConfig(root)
+// indexEmbedder finds the member of the embed chain p whose embeddings
+// built an index recorded as model, so queries are embedded the same way.
+func indexEmbedder(cfg *config.Config, p provider.Provider, model string) (provider.Embedder, bool) {
+	members := []provider.Provider{p}
+	if chain, ok := p.(*provider.FallbackProvider); ok {
+		members = chain.Providers
+	}
+	for _, m := range members {
+		if e, ok := m.(provider.Embedder); ok && embeddingModel(cfg, m.GetName()) == model {
+			return e, true
+		}
+	}
+	return nil, false
+}
 
-	selectedProvider := cfg.DefaultProvider
-	if repoCfg != nil && repoCfg.EnabledProvider != "" {
-		selectedProvider = repoCfg.EnabledProvider
+// selectProvider builds the provider the effective config routes task to,
+// chained with any fallback providers, and its model.
+func selectProvider(cfg *config.Config, task string) (provider.Provider, string, error) {
+	// In the effective config, a repo route that names a provider has
+	// already replaced the global one, whose model may be another provider's
+	route := cfg.Tasks[task]
+	selectedProvider := route.Provider
+	if selectedProvider == "" {
+		selectedProvider = cfg.DefaultProvider
+	}
+	if selectedProvider == "" {
+		return nil, "", fmt.Errorf("No AI provider configured.")
+	}
+
+	pCfg, ok := cfg.Providers[selectedProvider]
+	if !ok {
+		return nil, "", fmt.Errorf("Provider '%s' not configured.", selectedProvider)
 	}
-	pCfg := cfg.Providers[selectedProvider]
 
 	model := pCfg.DefaultModel
-	if repoCfg != nil && repoCfg.ModelOverride != "" {
-		model = repoCfg.ModelOverride
+	if task == "embed" {
+		if route.Model != "" {
+			pCfg.EmbeddingModel = route.Model
+		}
+		// The embed route only ever uses the embedding model
+		warnUnknownModel(selectedProvider, pCfg, pCfg.EmbeddingModel)
+	} else {
+		if route.Model != "" {
+			model = route.Model
+		}
+		warnUnknownModel(selectedProvider, pCfg, model)
 	}
 
+	for prefix, tokens := range cfg.ContextWindows {
+		provider.RegisterContextWindow(prefix, tokens)
+	}
+	// A window set on the provider is what Ollama actually gets as num_ctx
+	provider.RegisterContextWindow(model, pCfg.Generation.ContextWindow)
+
 	factory := &provider.ProviderFactory{}
-	return factory.GetProvider(selectedProvider, pCfg, model, cfg.SystemPrompt, cfg.CommitPromptTemplate)
+	p := factory.GetProvider(selectedProvider, pCfg, model, cfg.SystemPrompt, cfg.CommitPromptTemplate)
+	if p == nil {
+		return nil, "", fmt.Errorf("Failed to init provider.")
+	}
+	if err := provider.StartError(p); err != nil {
+		return nil, "", err
+	}
+
+	return factory.WithFallbacks(p, selectedProvider, cfg, cfg.FallbackProviders), model, nil
+}
+
+// reportFallback tells the user when a fallback provider stood in for the primary one.
+func reportFallback(p provider.Provider) {
+	if s, ok := p.(*provider.StructuredProvider); ok {
+		p = s.Provider
+	}
+	chain, ok := p.(*provider.FallbackProvider)
+	if !ok {
+		return
+	}
+	used, failures := chain.Used()
+	if used == "" || used == chain.GetName() {
+		return
+	}
+	fmt.Println(styleSubtle.Render(fmt.Sprintf("Answered by fallback provider '%s'.", used)))
+	for _, f := range failures {
+		fmt.Println(styleSubtle.Render("  " + f))
+	}
 }
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/eliau2005/ai-git/internal/provider"
+)
+
+// TestEmbeddingFallback checks that an index embedded by a fallback
+// records the fallback's model, and that chat then embeds queries with
+// that same member.
+func TestEmbeddingFallback(t *testing.T) {
+	r := newTestRepo(t)
+	cfg := r.config("default_provider: local\nfallback_providers: [backup]\n" +
+		"tasks:\n  embed:\n    model: nomic-embed-text\n" +
+		"providers:\n" +
+		"  local:\n    type: ollama\n    base_url: http://127.0.0.1:1\n    default_model: llama3\n    embedding_model: all-minilm\n    max_retries: -1\n" +
+		"  backup:\n    type: fake\n    embedding_model: fake-embed\n")
+
+	p, _, err := selectProvider(cfg, "embed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := embeddingModel(cfg, ""); got != "local/nomic-embed-text" {
+		t.Errorf("the route's embedding model = %q", got)
+	}
+	if got := servedBy(p); got != "local" {
+		t.Errorf("before any request, served by %q", got)
+	}
+
+	if _, err := p.(provider.Embedder).GenerateEmbedding(context.Background(), "package main"); err != nil {
+		t.Fatal(err)
+	}
+	served := embeddingModel(cfg, servedBy(p))
+	if served != "backup/fake-embed" {
+		t.Errorf("the index records %q, want the fallback's model", served)
+	}
+
+	e, ok := indexEmbedder(cfg, p, served)
+	if fake, isFake := e.(*provider.FakeProvider); !ok || !isFake || fake.GetName() != "backup" {
+		t.Errorf("indexEmbedder(%q) = %T, %v, want the backup entry", served, e, ok)
+	}
+	if e, ok := indexEmbedder(cfg, p, "local/nomic-embed-text"); !ok || e.(provider.Provider).GetName() != "local" {
+		t.Errorf("an index built by the primary isn't matched to it")
+	}
+	if _, ok := indexEmbedder(cfg, p, "local/all-minilm"); ok {
+		t.Error("an index built before the route's model override still matches")
+	}
+}
@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
 
 	"github.com/charmbracelet/huh"
 	"github.com/eliau2005/ai-git/internal/git"
+	"github.com/eliau2005/ai-git/internal/prompt"
 	"github.com/eliau2005/ai-git/internal/provider"
 )
 
@@ -19,9 +21,9 @@ func handleRefactor(args []string) {
 	}
 
 	targetFile := args[0]
-	var prompt string
+	var instruction string
 	if len(args) > 1 {
-		prompt = strings.Join(args[1:], " ")
+		instruction = strings.Join(args[1:], " ")
 	} else {
 		// Prompt the user interactively
 		form := huh.NewForm(
@@ -29,7 +31,7 @@ func handleRefactor(args []string) {
 				huh.NewInput().
 					Title("What should I refactor or change?").
 					Placeholder("e.g. Convert to Typescript, Optimize the loop, Add comments...").
-					Value(&prompt),
+					Value(&instruction),
 			),
 		)
 		if err := form.Run(); err != nil {
@@ -37,7 +39,7 @@ func handleRefactor(args []string) {
 		}
 	}
 
-	if prompt == "" {
+	if instruction == "" {
 		fmt.Println(styleError.Render("Prompt cannot be empty."))
 		return
 	}
@@ -49,7 +51,7 @@ func handleRefactor(args []string) {
 		return
 	}
 
-	activeProv := getActiveProvider()
+	activeProv, prompts := getActiveProvider("refactor")
 	refactorer, ok := activeProv.(provider.CodeRefactorer)
 	if !ok {
 		fmt.Println(styleError.Render("Current provider does not support autonomous refactoring."))
@@ -57,8 +59,8 @@ func handleRefactor(args []string) {
 	}
 
 	var newCode string
-	err = runSpinner(fmt.Sprintf("Agent is refactoring %s...", targetFile), func() error {
-		res, e := refactorer.RefactorCode(prompt, string(contentBytes))
+	err = runSpinner(fmt.Sprintf("Agent is refactoring %s...", targetFile), func(ctx context.Context) error {
+		res, e := refactorer.RefactorCode(prompt.With(ctx, prompts), instruction, string(contentBytes))
 		newCode = res
 		return e
 	})
@@ -67,6 +69,7 @@ func handleRefactor(args []string) {
 		fmt.Println(styleError.Render(fmt.Sprintf("Failed to refactor code: %v", err)))
 		return
 	}
+	reportFallback(activeProv)
 
 	var action string
 	form := huh.NewForm(
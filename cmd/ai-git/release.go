@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/charmbracelet/huh"
+	"github.com/eliau2005/ai-git/internal/prompt"
 	"github.com/eliau2005/ai-git/internal/provider"
 )
 
@@ -49,14 +50,18 @@ func handleRelease() {
 		return
 	}
 
-	activeProv := getActiveProvider()
+	activeProv, prompts := getActiveProvider("release")
 	chatter, ok := activeProv.(provider.Chatter)
 	if !ok {
 		fmt.Println(styleError.Render("Current provider does not support chat/changelog generation."))
 		return
 	}
 
-	prompt := "You are a release manager. Group the following commit messages into a beautifully formatted Markdown Changelog. Categorize them into '✨ Features', '🐛 Bug Fixes', and '🛠️ Maintenance' (or similar). Do NOT include markdown codeblocks around your entire response. Here are the commits:\n\n" + commits
+	changelogPrompt, err := prompts.Render(prompt.Changelog, prompt.Data{Commits: commits})
+	if err != nil {
+		fmt.Println(styleError.Render(err.Error()))
+		return
+	}
 
 	var changelog string
 
@@ -64,16 +69,19 @@ func handleRelease() {
 	fmt.Println(strings.Repeat("-", 40))
 	
 	var sb strings.Builder
-	err = chatter.AskChatStream(prompt, "", func(chunk string) {
+	ctx, stop := interruptContext()
+	err = chatter.AskChatStream(ctx, []provider.Message{{Role: "user", Content: changelogPrompt}}, "", func(chunk string) {
 		fmt.Print(chunk)
 		sb.WriteString(chunk)
 	})
+	stop()
 	fmt.Println("\n" + strings.Repeat("-", 40))
 
 	if err != nil {
 		fmt.Println(styleError.Render(fmt.Sprintf("Error generating changelog: %v", err)))
 		return
 	}
+	reportFallback(activeProv)
 
 	changelog = sb.String()
 
@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 
 	"github.com/charmbracelet/huh"
 	"github.com/eliau2005/ai-git/internal/git"
+	"github.com/eliau2005/ai-git/internal/prompt"
 	"github.com/eliau2005/ai-git/internal/provider"
 )
 
@@ -23,7 +25,7 @@ func handleResolve() {
 		return
 	}
 
-	activeProv := getActiveProvider()
+	activeProv, prompts := getActiveProvider("resolve")
 	resolver, ok := activeProv.(provider.ConflictResolver)
 	if !ok {
 		fmt.Println(styleError.Render("Current provider does not support conflict resolution."))
@@ -45,8 +47,8 @@ func handleResolve() {
 		content := string(contentBytes)
 
 		var resolvedContent string
-		err = runSpinner("AI is resolving conflicts...", func() error {
-			res, e := resolver.ResolveConflict(content)
+		err = runSpinner("AI is resolving conflicts...", func(ctx context.Context) error {
+			res, e := resolver.ResolveConflict(prompt.With(ctx, prompts), content)
 			resolvedContent = res
 			return e
 		})
@@ -55,6 +57,7 @@ func handleResolve() {
 			fmt.Println(styleError.Render(fmt.Sprintf("Failed to resolve %s: %v", file, err)))
 			continue
 		}
+		reportFallback(activeProv)
 
 		var action string
 		form := huh.NewForm(
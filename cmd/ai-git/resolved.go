@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/eliau2005/ai-git/internal/config"
+	"github.com/eliau2005/ai-git/internal/git"
+)
+
+// resolveConfig returns the effective configuration for the current
+// repository and command line.
+func resolveConfig() (*config.Resolved, error) {
+	root, _ := git.GetRepoRoot()
+	return config.Resolve(root, flagLayers(os.Args[2:])...)
+}
+
+// resolveSettings is resolveConfig for commands that never use a key or
+// token, which work on even while the secrets store is locked.
+func resolveSettings() (*config.Resolved, error) {
+	res, err := resolveConfig()
+	if errors.Is(err, config.ErrSecretsLocked) {
+		return res, nil
+	}
+	return res, err
+}
+
+// valueFlags are the flags of flagLayers that take a value.
+var valueFlags = []string{"--provider", "--model", "--candidates", "-n"}
+
+// flagLayers turns the flags that override settings into layers:
+// --provider, --model, --structured, --no-cache and --candidates N (or
+// -n N). --provider comes first so that --model applies to it.
+func flagLayers(args []string) []config.Layer {
+	var layers []config.Layer
+	for i := 0; i < len(args); i++ {
+		name, value, inline := strings.Cut(args[i], "=")
+		if slices.Contains(valueFlags, name) {
+			if !inline {
+				if i+1 >= len(args) {
+					continue
+				}
+				i++
+				value = args[i]
+			}
+		}
+		values := map[string]any{}
+		switch name {
+		case "--provider":
+			values["default_provider"] = value
+		case "--model":
+			values["model"] = value
+		case "--candidates", "-n":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				continue
+			}
+			section(values, "output")["candidates"] = n
+		case "--structured":
+			section(values, "output")["structured"] = true
+		case "--no-cache":
+			section(values, "cache")["disabled"] = true
+		default:
+			continue
+		}
+		layer := config.Layer{Name: config.LayerFlag, Source: name, Values: values}
+		if name == "--provider" {
+			layers = append([]config.Layer{layer}, layers...)
+		} else {
+			layers = append(layers, layer)
+		}
+	}
+	return layers
+}
+
+func section(values map[string]any, name string) map[string]any {
+	m, ok := values[name].(map[string]any)
+	if !ok {
+		m = map[string]any{}
+		values[name] = m
+	}
+	return m
+}
+
+// handleConfigShow prints the effective configuration; with --origin,
+// also the layer and file each value comes from. Other arguments filter
+// by key prefix, e.g. `ai-git config show providers.openai`.
+func handleConfigShow(args []string) {
+	res, err := resolveSettings()
+	if err != nil {
+		fmt.Println(styleError.Render(fmt.Sprintf("Config Error: %v", err)))
+		return
+	}
+
+	origin := false
+	var prefixes []string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--origin":
+			origin = true
+		case slices.Contains(valueFlags, args[i]):
+			i++
+		case !strings.HasPrefix(args[i], "-"):
+			prefixes = append(prefixes, args[i])
+		}
+	}
+
+	for _, s := range res.Settings() {
+		if !matchesPrefix(s.Key, prefixes) {
+			continue
+		}
+		line := fmt.Sprintf("%s = %s", s.Key, displayValue(s.Key, s.Value))
+		if origin {
+			line += styleSubtle.Render(fmt.Sprintf("  [%s: %s]", s.Layer, s.Source))
+		}
+		fmt.Println(line)
+	}
+}
+
+func matchesPrefix(key string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, p := range prefixes {
+		if key == p || strings.HasPrefix(key, p+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// displayValue formats a value for one line, masking keys and tokens and
+// shortening multi-line prompts.
+func displayValue(key string, v any) string {
+	s := fmt.Sprint(v)
+	if str, ok := v.(string); ok {
+		if isSecretKey(key) && !config.IsSecretRef(str) {
+			return maskSecret(str)
+		}
+		first, _, multiline := strings.Cut(str, "\n")
+		s = strconv.Quote(first)
+		if multiline {
+			s += " …"
+		}
+	}
+	if list, ok := v.([]any); ok {
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = fmt.Sprint(item)
+		}
+		s = "[" + strings.Join(items, ", ") + "]"
+	}
+	return s
+}
+
+// maskSecret hides all but the last four characters of a key or token;
+// references to environment variables and commands are shown as they are.
+func maskSecret(s string) string {
+	if config.IsSecretRef(s) {
+		return s
+	}
+	if len(s) <= 8 {
+		return "********"
+	}
+	return "********" + s[len(s)-4:]
+}
+
+func isSecretKey(key string) bool {
+	return strings.HasSuffix(key, ".api_key") || strings.HasSuffix(key, ".token") || strings.Contains(key, ".headers.")
+}
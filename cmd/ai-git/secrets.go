@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/charmbracelet/huh"
+	"github.com/eliau2005/ai-git/internal/config"
+)
+
+func handleSecrets(args []string) {
+	if len(args) == 0 || (args[0] != "migrate" && args[0] != "status") {
+		fmt.Println("Usage: ai-git secrets migrate [--passphrase] | ai-git secrets status")
+		return
+	}
+
+	if args[0] == "status" {
+		secretsStatus()
+		return
+	}
+
+	var passphrase string
+	if hasFlag("--passphrase") {
+		passphrase = os.Getenv(config.PassphraseEnv)
+	}
+	if hasFlag("--passphrase") && passphrase == "" {
+		var confirm string
+		form := huh.NewForm(
+			huh.NewGroup(
+				huh.NewInput().
+					Title("New passphrase").
+					Password(true).
+					Value(&passphrase),
+				huh.NewInput().
+					Title("Repeat passphrase").
+					Password(true).
+					Value(&confirm),
+			),
+		)
+		if err := form.Run(); err != nil {
+			fmt.Println(styleError.Render(fmt.Sprintf("Cannot read passphrase: %v", err)))
+			return
+		}
+		if passphrase == "" || passphrase != confirm {
+			fmt.Println(styleError.Render("Passphrases are empty or don't match."))
+			return
+		}
+	}
+
+	count, err := config.MigrateSecrets(passphrase)
+	if err != nil {
+		fmt.Println(styleError.Render(fmt.Sprintf("Migration failed: %v", err)))
+		return
+	}
+	mode, _ := config.SecretsMode()
+	if mode == "" {
+		fmt.Println(styleSubtle.Render("No API keys or tokens to encrypt."))
+		return
+	}
+	fmt.Println(styleSuccess.Render(fmt.Sprintf("Moved %d plaintext secrets into the encrypted store (%s).", count, mode)))
+}
+
+func secretsStatus() {
+	mode, err := config.SecretsMode()
+	if err != nil {
+		fmt.Println(styleError.Render(fmt.Sprintf("Secrets Error: %v", err)))
+		return
+	}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Println(styleError.Render(fmt.Sprintf("Config Error: %v", err)))
+		return
+	}
+	if mode == "" {
+		fmt.Println("Encrypted store: none")
+	} else {
+		fmt.Printf("Encrypted store: %s\n", mode)
+	}
+
+	var names []string
+	for name, p := range cfg.Providers {
+		if p.APIKey != "" && !config.IsSecretRef(p.APIKey) {
+			names = append(names, "providers."+name)
+		}
+	}
+	for name, p := range cfg.Platforms {
+		if p.Token != "" && !config.IsSecretRef(p.Token) {
+			names = append(names, "platforms."+name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Println("  " + name)
+	}
+
+	plain, err := config.PlaintextSecrets()
+	if err == nil && len(plain) > 0 {
+		fmt.Println(styleError.Render(fmt.Sprintf("Plaintext in config.yaml: %v (run `ai-git secrets migrate`)", plain)))
+	}
+}
+
+// askPassphrase unlocks a passphrase-protected store when AI_GIT_PASSPHRASE
+// isn't set. Without a terminal, e.g. in a git hook, it fails instead.
+func askPassphrase() (string, error) {
+	if !isTerminal(os.Stdin) {
+		return "", fmt.Errorf("there is no terminal to ask for the passphrase: set %s", config.PassphraseEnv)
+	}
+	var passphrase string
+	err := huh.NewInput().
+		Title("Passphrase for ai-git secrets").
+		Password(true).
+		Value(&passphrase).
+		Run()
+	return passphrase, err
+}
+
+// isTerminal reports whether f looks like a terminal; /dev/null is a
+// character device too, so it's ruled out explicitly.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
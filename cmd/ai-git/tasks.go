@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/eliau2005/ai-git/internal/config"
+)
+
+// taskProblems lists `tasks:` entries with an unknown command or provider.
+func taskProblems(cfg *config.Config, repoCfg *config.RepoConfig) []string {
+	var problems []string
+	checkAll := func(where string, tasks map[string]config.TaskConfig) {
+		names := make([]string, 0, len(tasks))
+		for name := range tasks {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if !slices.Contains(config.TaskNames, name) {
+				problems = append(problems, fmt.Sprintf("%s: unknown task '%s'", where, name))
+			} else if p := tasks[name].Provider; p != "" {
+				if _, ok := cfg.Providers[p]; !ok {
+					problems = append(problems, fmt.Sprintf("%s: %s: provider '%s' not configured", where, name, p))
+				}
+			}
+		}
+	}
+	checkAll("global", cfg.Tasks)
+	if repoCfg != nil {
+		checkAll("repo", repoCfg.Tasks)
+	}
+	return problems
+}
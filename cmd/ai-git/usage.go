@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/eliau2005/ai-git/internal/provider"
+	"github.com/eliau2005/ai-git/internal/usage"
+)
+
+// recordUsage appends every API call's token counts to the usage ledger,
+// attributed to the running command.
+func recordUsage(command string) {
+	provider.SetUsageHook(func(u provider.Usage) {
+		usage.Append(usage.Record{
+			Time:         time.Now(),
+			Kind:         u.Kind,
+			Provider:     u.Provider,
+			Model:        u.Model,
+			Command:      command,
+			InputTokens:  u.InputTokens,
+			OutputTokens: u.OutputTokens,
+		})
+	})
+}
+
+func handleUsage(args []string) {
+	fmt.Println(styleTitle.Render("Token Usage"))
+
+	days := 30
+	for i, arg := range args {
+		if arg == "--days" && i+1 < len(args) {
+			if d, err := strconv.Atoi(args[i+1]); err == nil && d > 0 {
+				days = d
+			}
+		}
+	}
+
+	res, err := resolveSettings()
+	if err != nil {
+		fmt.Println(styleError.Render(fmt.Sprintf("Config Error: %v", err)))
+		return
+	}
+	cfg := res.Config
+
+	now := time.Now()
+	since := time.Date(now.Year(), now.Month(), now.Day()-days+1, 0, 0, 0, 0, now.Location())
+	records, err := usage.Load(since)
+	if err != nil {
+		fmt.Println(styleError.Render(fmt.Sprintf("Failed to read usage ledger: %v", err)))
+		return
+	}
+	if len(records) == 0 {
+		fmt.Println(styleSubtle.Render(fmt.Sprintf("No usage recorded in the last %d days.", days)))
+		return
+	}
+	fmt.Println(styleSubtle.Render(fmt.Sprintf("Last %d days, since %s", days, since.Format("2006-01-02"))))
+
+	sections := []struct {
+		title string
+		key   func(usage.Record) string
+	}{
+		{"Provider", func(r usage.Record) string { return r.Provider }},
+		{"Model", func(r usage.Record) string { return r.Model }},
+		{"Command", func(r usage.Record) string { return r.Command }},
+		{"Day", func(r usage.Record) string { return r.Time.Local().Format("2006-01-02") }},
+	}
+	for _, s := range sections {
+		fmt.Println()
+		printUsageTable(s.title, usage.Summarize(records, cfg.Prices, s.key))
+	}
+
+	total := usage.Summarize(records, cfg.Prices, func(usage.Record) string { return "Total" })
+	fmt.Println()
+	printUsageTable("", total)
+
+	if total[0].Unpriced > 0 {
+		fmt.Println()
+		fmt.Println(styleSubtle.Render(fmt.Sprintf("%d call(s) used models without a known price; add them under `prices:` in the config.", total[0].Unpriced)))
+	}
+}
+
+func printUsageTable(title string, rows []usage.Row) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if title != "" {
+		fmt.Fprintf(w, "%s\tCalls\tInput\tOutput\tCost\t\n", title)
+	}
+	for _, r := range rows {
+		cost := fmt.Sprintf("$%.4f", r.Cost)
+		if r.Unpriced == r.Calls {
+			cost = "-"
+		} else if r.Unpriced > 0 {
+			cost += "+"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\t\n", r.Key, r.Calls, r.InputTokens, r.OutputTokens, cost)
+	}
+	w.Flush()
+}
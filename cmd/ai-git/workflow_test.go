@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/eliau2005/ai-git/internal/config"
+	"github.com/eliau2005/ai-git/internal/git"
+	"github.com/eliau2005/ai-git/internal/prompt"
+	"github.com/eliau2005/ai-git/internal/provider"
+)
+
+// testRepo is a fresh repository, made the working directory, with a
+// home directory of its own for the global config.
+type testRepo struct {
+	t    *testing.T
+	home string
+}
+
+func newTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	t.Setenv("AI_GIT_SYSTEM_CONFIG", filepath.Join(home, "none.yaml"))
+	for _, env := range []string{"AI_GIT_PROVIDER", "AI_GIT_MODEL", "AI_GIT_COMMIT_STYLE", "AI_GIT_LANGUAGE", provider.RecordEnv} {
+		t.Setenv(env, "")
+	}
+	t.Chdir(t.TempDir())
+
+	r := &testRepo{t: t, home: home}
+	r.git("init", "-q", "-b", "main")
+	return r
+}
+
+// git runs a git command that must succeed.
+func (r *testRepo) git(args ...string) {
+	r.t.Helper()
+	if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		r.t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
+
+func (r *testRepo) write(path, data string) {
+	r.t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		r.t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+// commit stages everything and commits it.
+func (r *testRepo) commit(msg string) {
+	r.t.Helper()
+	r.git("add", ".")
+	r.git("commit", "-q", "-m", msg)
+}
+
+// config writes the global config and resolves it.
+func (r *testRepo) config(yaml string) *config.Config {
+	r.t.Helper()
+	r.write(filepath.Join(r.home, ".config", "ai-git", "config.yaml"), yaml)
+	res, err := resolveConfig()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return res.Config
+}
+
+// cassette saves OpenAI chat completions answering with replies, in order,
+// and returns its path.
+func (r *testRepo) cassette(replies ...string) string {
+	r.t.Helper()
+	var c provider.Cassette
+	for _, content := range replies {
+		body, _ := json.Marshal(map[string]any{
+			"choices": []any{map[string]any{"message": map[string]any{"role": "assistant", "content": content}}},
+			"usage":   map[string]any{"prompt_tokens": 100, "completion_tokens": 10},
+		})
+		c.Interactions = append(c.Interactions, provider.Interaction{
+			Provider: "openai",
+			Request:  provider.RecordedRequest{Method: "POST", URL: "https://api.openai.com/v1/chat/completions"},
+			Response: provider.RecordedResponse{Status: 200, Body: string(body)},
+		})
+	}
+	path := filepath.Join(r.home, "cassette.json")
+	if err := c.Save(path); err != nil {
+		r.t.Fatal(err)
+	}
+	return path
+}
+
+// replayConfig routes every task to a replay of the cassette at path.
+func replayConfig(path string) string {
+	return "default_provider: openai\nproviders:\n  openai:\n    type: replay\n    cassette: " + path +
+		"\n    default_model: gpt-4o-mini\n    max_retries: -1\n"
+}
+
+// TestCommitWorkflowReplay runs the commit pipeline, from the staged diff
+// of a fresh repository to the final candidates, against a cassette: the
+// recorded answer breaks the commit style, so the rewrite request is
+// replayed too.
+func TestCommitWorkflowReplay(t *testing.T) {
+	r := newTestRepo(t)
+	r.write("parser.go", "package parser\n\nfunc Parse() {}\n")
+	r.commit("initial")
+	r.write("parser.go", "package parser\n\nfunc Parse() error { return nil }\n")
+	r.git("add", ".")
+
+	cfg := r.config(replayConfig(r.cassette(
+		"Here is your commit message:\n\nReturn errors from Parse\n\nCallers can now handle bad input.",
+		"fix(parser): return errors from Parse\n\nCallers can now handle bad input.",
+	)))
+	p, model, err := selectProvider(cfg, "commit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := git.GetRepoRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err := git.DiffStagedFiltered(root)
+	if err != nil || !strings.Contains(diff, "func Parse() error") {
+		t.Fatalf("staged diff = %q, %v", diff, err)
+	}
+
+	prompts, _ := workflowPrompts(cfg, "commit", diff)
+	if prompts.Data.Branch != "main" || len(prompts.Data.Files) != 1 || prompts.Data.Files[0] != "parser.go" {
+		t.Errorf("prompt data = %+v", prompts.Data)
+	}
+	rules := commitRules(cfg)
+	generate := withRules(newGenerateFunc(p, model, diff, "\n"+rules.Instructions()+"\n", 1, prompts), p, rules, prompts)
+	candidates, err := generate(context.Background(), func(string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "fix(parser): return errors from Parse\n\nCallers can now handle bad input."
+	if len(candidates) != 1 || candidates[0] != want {
+		t.Errorf("candidates = %q, want %q", candidates, want)
+	}
+	if _, err := generate(context.Background(), func(string) {}); err == nil || !strings.Contains(err.Error(), "no recorded response left") {
+		t.Errorf("a third request got %v, want the cassette to be used up", err)
+	}
+}
+
+// TestPRWorkflowFake generates a pull request for a feature branch: the
+// provider must get the PR prompt, not the commit one, with the branch
+// diff and its files.
+func TestPRWorkflowFake(t *testing.T) {
+	r := newTestRepo(t)
+	r.write("server.go", "package server\n")
+	r.commit("initial")
+	r.git("checkout", "-q", "-b", "feature/timeouts")
+	r.write("server.go", "package server\n\nconst Timeout = 30\n")
+	r.write("docs/timeouts.md", "# Timeouts\n")
+	r.commit("add timeouts")
+
+	cfg := r.config("default_provider: scripted\nproviders:\n  scripted:\n    type: fake\n    responses:\n" +
+		"      - \"Add request timeouts\\n\\nServers now give up after 30 seconds.\"\n" +
+		"prompts:\n  pr: \"PR from {{.Branch}} for {{len .Files}} files:\\n{{.Diff}}{{.Context}}\"\n")
+	p, model, err := selectProvider(cfg, "pr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake, ok := p.(*provider.FakeProvider)
+	if !ok {
+		t.Fatalf("provider is %T, want the fake", p)
+	}
+	diff, err := git.DiffBranches("main", "feature/timeouts")
+	if err != nil || !strings.Contains(diff, "const Timeout = 30") {
+		t.Fatalf("branch diff = %q, %v", diff, err)
+	}
+
+	prompts, template := workflowPrompts(cfg, "pr", diff)
+	if template != cfg.Prompts[prompt.PR] {
+		t.Errorf("template = %q, want the PR prompt", template)
+	}
+	candidates, err := newGenerateFunc(p, model, diff, "\nBase: main, Head: feature/timeouts.", 1, prompts)(context.Background(), func(string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 1 || candidates[0] != "Add request timeouts\n\nServers now give up after 30 seconds." {
+		t.Errorf("candidates = %q", candidates)
+	}
+	title, description := splitMessage(candidates[0])
+	if title != "Add request timeouts" || description != "Servers now give up after 30 seconds." {
+		t.Errorf("split into %q and %q", title, description)
+	}
+
+	sent := fake.Prompts()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d prompts, want 1", len(sent))
+	}
+	for _, want := range []string{"PR from feature/timeouts for 2 files:", "+const Timeout = 30", "Base: main, Head: feature/timeouts."} {
+		if !strings.Contains(sent[0], want) {
+			t.Errorf("prompt lacks %q:\n%s", want, sent[0])
+		}
+	}
+}
+
+// TestResolveReplay resolves a real merge conflict against a cassette whose
+// answer comes wrapped in a code fence.
+func TestResolveReplay(t *testing.T) {
+	r := newTestRepo(t)
+	r.write("greet.txt", "hello\n")
+	r.commit("initial")
+	r.git("checkout", "-q", "-b", "loud")
+	r.write("greet.txt", "HELLO\n")
+	r.commit("shout")
+	r.git("checkout", "-q", "main")
+	r.write("greet.txt", "hello, world\n")
+	r.commit("address the world")
+	if err := exec.Command("git", "merge", "-q", "loud").Run(); err == nil {
+		t.Fatal("the merge went through without a conflict")
+	}
+
+	conflicts, err := git.GetConflictingFiles()
+	if err != nil || len(conflicts) != 1 || conflicts[0] != "greet.txt" {
+		t.Fatalf("conflicts = %q, %v", conflicts, err)
+	}
+	content, err := os.ReadFile(conflicts[0])
+	if err != nil || !strings.Contains(string(content), "<<<<<<<") {
+		t.Fatalf("greet.txt = %q, %v", content, err)
+	}
+
+	cfg := r.config(replayConfig(r.cassette("```\nHELLO, WORLD\n```")))
+	p, _, err := selectProvider(cfg, "resolve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver, ok := p.(provider.ConflictResolver)
+	if !ok {
+		t.Fatalf("provider %T can't resolve conflicts", p)
+	}
+	resolved, err := resolver.ResolveConflict(prompt.With(context.Background(), promptSet(cfg)), string(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved != "HELLO, WORLD" {
+		t.Errorf("resolved = %q, want the fence stripped", resolved)
+	}
+}
+
+// TestRefactorFake refactors a tracked file with a prompt override, which
+// must reach the provider with the instruction and the file.
+func TestRefactorFake(t *testing.T) {
+	r := newTestRepo(t)
+	r.write("sum.go", "package sum\n\nfunc Sum(a, b int) int { return a + b }\n")
+	r.commit("initial")
+
+	cfg := r.config("default_provider: scripted\nproviders:\n  scripted:\n    type: fake\n    responses:\n" +
+		"      - \"```go\\npackage sum\\n\\n// Sum adds a and b.\\nfunc Sum(a, b int) int { return a + b }\\n```\"\n" +
+		"prompts:\n  refactor: \"Task: {{.Instruction}}\\n---\\n{{.Content}}\"\n")
+	p, _, err := selectProvider(cfg, "refactor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refactorer, ok := p.(provider.CodeRefactorer)
+	if !ok {
+		t.Fatalf("provider %T can't refactor", p)
+	}
+	content, err := os.ReadFile("sum.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newCode, err := refactorer.RefactorCode(prompt.With(context.Background(), promptSet(cfg)), "Document Sum", string(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newCode != "package sum\n\n// Sum adds a and b.\nfunc Sum(a, b int) int { return a + b }" {
+		t.Errorf("refactored code = %q", newCode)
+	}
+	if sent := p.(*provider.FakeProvider).Prompts(); len(sent) != 1 || sent[0] != "Task: Document Sum\n---\n"+string(content) {
+		t.Errorf("prompts = %q", sent)
+	}
+
+	if err := os.WriteFile("sum.go", []byte(newCode), 0644); err != nil {
+		t.Fatal(err)
+	}
+	diff, err := git.Diff("sum.go")
+	if err != nil || !strings.Contains(diff, "// Sum adds a and b.") {
+		t.Errorf("diff of the refactored file = %q, %v", diff, err)
+	}
+}
@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	DefaultTTL      = 24 * time.Hour
+	DefaultMaxBytes = 20 << 20
+)
+
+// Cache stores AI responses as one JSON file per key. Entries expire
+// after TTL, and the oldest are evicted once the directory exceeds MaxBytes.
+type Cache struct {
+	Dir      string
+	TTL      time.Duration
+	MaxBytes int64
+}
+
+type entry struct {
+	Created   time.Time `json:"created"`
+	Responses []string  `json:"responses"`
+}
+
+// GetCacheDir returns the cache directory inside a repository's git
+// directory, or the user cache dir when not inside one.
+func GetCacheDir(gitDir string) (string, error) {
+	if gitDir != "" {
+		return filepath.Join(gitDir, "ai-git-cache"), nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ai-git", "responses"), nil
+}
+
+// Key hashes everything that can change a response into a file-safe key.
+func Key(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		// Length-prefix each part so ("ab","c") and ("a","bc") differ
+		fmt.Fprintf(h, "%d:%s", len(p), p)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.Dir, key+".json")
+}
+
+func (c *Cache) ttl() time.Duration {
+	if c.TTL <= 0 {
+		return DefaultTTL
+	}
+	return c.TTL
+}
+
+func (c *Cache) maxBytes() int64 {
+	if c.MaxBytes <= 0 {
+		return DefaultMaxBytes
+	}
+	return c.MaxBytes
+}
+
+// Get returns the cached responses for key, if present and fresh.
+func (c *Cache) Get(key string) ([]string, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil || len(e.Responses) == 0 {
+		return nil, false
+	}
+	if time.Since(e.Created) > c.ttl() {
+		os.Remove(c.path(key))
+		return nil, false
+	}
+	return e.Responses, true
+}
+
+// Put stores responses under key and trims the cache back under its size limit.
+func (c *Cache) Put(key string, responses []string) error {
+	if err := os.MkdirAll(c.Dir, 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry{Created: time.Now(), Responses: responses})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.path(key), data, 0600); err != nil {
+		return err
+	}
+	return c.prune()
+}
+
+// prune drops expired entries, then the oldest ones until the cache fits MaxBytes.
+func (c *Cache) prune() error {
+	files, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return err
+	}
+
+	type file struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var kept []file
+	var total int64
+	for _, f := range files {
+		info, err := f.Info()
+		if err != nil || f.IsDir() || filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+		p := filepath.Join(c.Dir, f.Name())
+		if time.Since(info.ModTime()) > c.ttl() {
+			os.Remove(p)
+			continue
+		}
+		kept = append(kept, file{path: p, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+	}
+
+	sort.Slice(kept, func(i, j int) bool {
+		return kept[i].modTime.Before(kept[j].modTime)
+	})
+	for _, f := range kept {
+		if total <= c.maxBytes() {
+			break
+		}
+		os.Remove(f.path)
+		total -= f.size
+	}
+	return nil
+}
+
+// Clear removes every cached response and reports how many were deleted.
+func (c *Cache) Clear() (int, error) {
+	files, err := os.ReadDir(c.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	count := 0
+	for _, f := range files {
+		if filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.Dir, f.Name())); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
@@ -0,0 +1,29 @@
+package cache
+
+import "testing"
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		same bool
+	}{
+		{"identical", []string{"openai", "gpt-4o", "diff"}, []string{"openai", "gpt-4o", "diff"}, true},
+		{"different part", []string{"openai", "gpt-4o", "diff"}, []string{"openai", "gpt-4o-mini", "diff"}, false},
+		{"shifted boundary", []string{"ab", "c"}, []string{"a", "bc"}, false},
+		{"joined parts", []string{"a", "b"}, []string{"ab"}, false},
+		{"empty part", []string{"a", ""}, []string{"a"}, false},
+		{"order", []string{"a", "b"}, []string{"b", "a"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ka, kb := Key(tt.a...), Key(tt.b...)
+			if (ka == kb) != tt.same {
+				t.Errorf("Key(%q) == Key(%q) is %v, want %v", tt.a, tt.b, ka == kb, tt.same)
+			}
+			if len(ka) != 64 {
+				t.Errorf("Key(%q) = %q is not a hex SHA-256", tt.a, ka)
+			}
+		})
+	}
+}
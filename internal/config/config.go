@@ -1,9 +1,12 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/eliau2005/ai-git/internal/prompt"
 	"gopkg.in/yaml.v3"
 )
 
@@ -13,33 +16,146 @@ type Config struct {
 	Platforms            map[string]PlatformConfig `yaml:"platforms,omitempty"`
 	Output               OutputConfig              `yaml:"output"`
 	SystemPrompt         string                    `yaml:"system_prompt,omitempty"`
-	CommitPromptTemplate string                    `yaml:"commit_prompt_template,omitempty"`
+	CommitPromptTemplate string                    `yaml:"commit_prompt_template,omitempty"` // superseded by prompts.commit
+	Prompts              map[string]string         `yaml:"prompts,omitempty"`                // text/template overrides keyed by task
+	FallbackProviders    []string                  `yaml:"fallback_providers,omitempty"`     // tried in order when the default provider fails
+	Cache                CacheConfig               `yaml:"cache,omitempty"`
+	Prices               map[string]Price          `yaml:"prices,omitempty"`          // keyed by model name prefix
+	Tasks                map[string]TaskConfig     `yaml:"tasks,omitempty"`           // keyed by command, see TaskNames
+	ContextWindows       map[string]int            `yaml:"context_windows,omitempty"` // tokens, keyed by model name prefix
+
+	dropped map[string]bool // store entries to delete on Save, see forgetSecrets
+	raw     map[string]any  // the values as written, see GetValue
+}
+
+// TaskNames are the commands that can be routed under `tasks:`.
+var TaskNames = []string{"commit", "amend", "pr", "release", "resolve", "refactor", "fix", "chat", "embed"}
+
+// TaskConfig routes one command to a provider and model; empty fields
+// fall back to the defaults.
+type TaskConfig struct {
+	Provider string `yaml:"provider,omitempty"`
+	Model    string `yaml:"model,omitempty"` // for embed, the embedding model
+}
+
+// Price is a model's cost in USD per million tokens.
+type Price struct {
+	Input  float64 `yaml:"input"`
+	Output float64 `yaml:"output"`
+}
+
+type CacheConfig struct {
+	Disabled  bool          `yaml:"disabled,omitempty"`
+	TTL       time.Duration `yaml:"ttl,omitempty"`         // default 24h
+	MaxSizeMB int           `yaml:"max_size_mb,omitempty"` // default 20
 }
 
 type PlatformConfig struct {
-	Token string `yaml:"token"`
+	Token string `yaml:"token,omitempty"`
 	Host  string `yaml:"host,omitempty"`
 }
 
 type ProviderConfig struct {
-	APIKey       string            `yaml:"api_key"`
-	DefaultModel string            `yaml:"default_model"`
-	CustomModels []string          `yaml:"custom_models,omitempty"`
-	BaseURL      string            `yaml:"base_url,omitempty"`
+	// Type selects the implementation for a named provider entry, e.g.
+	// `type: openai` for any OpenAI-compatible endpoint. Defaults to the entry's name.
+	Type           string            `yaml:"type,omitempty"`
+	APIKey         string            `yaml:"api_key,omitempty"`
+	DefaultModel   string            `yaml:"default_model"`
+	CustomModels   []string          `yaml:"custom_models,omitempty"`
+	BaseURL        string            `yaml:"base_url,omitempty"`
+	Headers        map[string]string `yaml:"headers,omitempty"`
+	APIVersion     string            `yaml:"api_version,omitempty"` // sent as ?api-version= (Azure OpenAI)
+	EmbeddingModel string            `yaml:"embedding_model,omitempty"`
+	Timeout        time.Duration     `yaml:"timeout,omitempty"`     // e.g. "45s"; for streams, only the wait for the first byte
+	MaxRetries     int               `yaml:"max_retries,omitempty"` // retries on 429/5xx; 0 = default (3), negative disables
+	Cassette       string            `yaml:"cassette,omitempty"`    // replay HTTP exchanges from this file instead of the network
+	Responses      []string          `yaml:"responses,omitempty"`   // scripted answers for `type: fake`
+	Command        string            `yaml:"command,omitempty"`     // plugin executable for `type: exec`
+	Args           []string          `yaml:"args,omitempty"`        // plugin arguments; if empty, command is split on spaces
+	Generation     GenerationConfig  `yaml:",inline"`
+}
+
+// GenerationConfig holds the sampling parameters sent with every request.
+// Unset fields leave the provider's own defaults in place.
+type GenerationConfig struct {
+	Temperature   *float64       `yaml:"temperature,omitempty" json:"temperature,omitempty"`
+	TopP          *float64       `yaml:"top_p,omitempty" json:"top_p,omitempty"`
+	MaxTokens     int            `yaml:"max_tokens,omitempty" json:"max_tokens,omitempty"` // reply length limit
+	Stop          []string       `yaml:"stop,omitempty" json:"stop,omitempty"`
+	ContextWindow int            `yaml:"context_window,omitempty" json:"context_window,omitempty"` // tokens; also sent to Ollama as num_ctx
+	Options       map[string]any `yaml:"options,omitempty" json:"options,omitempty"`               // extra Ollama options, e.g. num_gpu
+}
+
+// Merge returns g with every field that is set in over replacing its own.
+func (g GenerationConfig) Merge(over GenerationConfig) GenerationConfig {
+	if over.Temperature != nil {
+		g.Temperature = over.Temperature
+	}
+	if over.TopP != nil {
+		g.TopP = over.TopP
+	}
+	if over.MaxTokens != 0 {
+		g.MaxTokens = over.MaxTokens
+	}
+	if over.Stop != nil {
+		g.Stop = over.Stop
+	}
+	if over.ContextWindow != 0 {
+		g.ContextWindow = over.ContextWindow
+	}
+	if len(over.Options) > 0 {
+		options := make(map[string]any, len(g.Options)+len(over.Options))
+		for k, v := range g.Options {
+			options[k] = v
+		}
+		for k, v := range over.Options {
+			options[k] = v
+		}
+		g.Options = options
+	}
+	return g
+}
+
+// Kind returns the provider implementation to use for the entry called name.
+func (p ProviderConfig) Kind(name string) string {
+	if p.Type != "" {
+		return p.Type
+	}
+	return name
 }
 
 type OutputConfig struct {
-	Language string `yaml:"language"`
-	Style    string `yaml:"style"`
+	Language         string   `yaml:"language"`
+	Style            string   `yaml:"style"`
+	Candidates       int      `yaml:"candidates,omitempty"`         // commit messages to generate per run; --candidates overrides
+	Structured       bool     `yaml:"structured,omitempty"`         // generate JSON and render it in the commit style
+	MaxSubjectLength int      `yaml:"max_subject_length,omitempty"` // title length limit; default 72, 50 for the short style
+	Types            []string `yaml:"types,omitempty"`              // prefixes allowed by the conventional style
 }
 
 type RepoConfig struct {
-	EnabledProvider string `yaml:"enabled_provider"`
-	ModelOverride   string `yaml:"model_override"`
-	CommitStyle     string `yaml:"commit_style"`
-	Language        string `yaml:"language"`
+	EnabledProvider string `yaml:"enabled_provider,omitempty"`
+	ModelOverride   string `yaml:"model_override,omitempty"`
+	CommitStyle     string `yaml:"commit_style,omitempty"`
+	Language        string `yaml:"language,omitempty"`
+	// MaxSubjectLength and Types override the global output settings
+	MaxSubjectLength int      `yaml:"max_subject_length,omitempty"`
+	Types            []string `yaml:"types,omitempty"`
+	// FallbackProviders replaces the global list for this repository
+	FallbackProviders []string `yaml:"fallback_providers,omitempty"`
+	// Prompts override the global prompts task by task
+	Prompts map[string]string `yaml:"prompts,omitempty"`
+	// Tasks override the global routing command by command
+	Tasks map[string]TaskConfig `yaml:"tasks,omitempty"`
+	// Generation overrides the provider's parameters in this repository
+	Generation GenerationConfig `yaml:",inline"`
+
+	raw map[string]any // the file as written, see GetValue
 }
 
+// LoadConfig reads the global config and fills in the keys and tokens of
+// the encrypted store. If the store can't be unlocked, the config comes
+// back without them, along with an error wrapping ErrSecretsLocked.
 func LoadConfig() (*Config, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -47,8 +163,7 @@ func LoadConfig() (*Config, error) {
 	}
 	configPath := filepath.Join(home, ".config", "ai-git", "config.yaml")
 
-	defaultSystemPrompt := "You are an expert developer. Generate a raw git commit message. Output ONLY the message. Structure: a short title, then a blank line, then a description. No conversational filler, no quotes, no backticks."
-	defaultCommitPrompt := "Generate a raw git commit message for the changes below. Output ONLY the message. Structure: a short title, then a blank line, then a description. No conversational filler, no quotes, no backticks.\n\nChanges:\n%s\n\n%s"
+	defaultCommitPrompt := prompt.Defaults[prompt.Commit]
 
 	data, err := os.ReadFile(configPath)
 	if err != nil {
@@ -56,7 +171,7 @@ func LoadConfig() (*Config, error) {
 			return &Config{
 				Providers:            make(map[string]ProviderConfig),
 				Platforms:            make(map[string]PlatformConfig),
-				SystemPrompt:         defaultSystemPrompt,
+				SystemPrompt:         prompt.DefaultSystem,
 				CommitPromptTemplate: defaultCommitPrompt,
 			}, nil
 		}
@@ -67,19 +182,27 @@ func LoadConfig() (*Config, error) {
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		return nil, err
 	}
+	if err := yaml.Unmarshal(data, &cfg.raw); err != nil {
+		return nil, err
+	}
 	
 	if cfg.Platforms == nil {
 		cfg.Platforms = make(map[string]PlatformConfig)
 	}
 
+	secretsErr := cfg.applySecrets()
+	if secretsErr != nil && !errors.Is(secretsErr, ErrSecretsLocked) {
+		return nil, secretsErr
+	}
+
 	if cfg.SystemPrompt == "" {
-		cfg.SystemPrompt = defaultSystemPrompt
+		cfg.SystemPrompt = prompt.DefaultSystem
 	}
 	if cfg.CommitPromptTemplate == "" {
 		cfg.CommitPromptTemplate = defaultCommitPrompt
 	}
 
-	return &cfg, nil
+	return &cfg, secretsErr
 }
 
 func LoadRepoConfig(rootPath string) (*RepoConfig, error) {
@@ -96,6 +219,9 @@ func LoadRepoConfig(rootPath string) (*RepoConfig, error) {
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		return nil, err
 	}
+	if err := yaml.Unmarshal(data, &cfg.raw); err != nil {
+		return nil, err
+	}
 	return &cfg, nil
 }
 
@@ -110,7 +236,11 @@ func (cfg *Config) Save() error {
 	}
 	configPath := filepath.Join(configDir, "config.yaml")
 
-	data, err := yaml.Marshal(cfg)
+	// Keys and tokens go to the encrypted store, never to config.yaml
+	if err := cfg.saveSecrets(false); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(cfg.withoutSecrets())
 	if err != nil {
 		return err
 	}
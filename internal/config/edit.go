@@ -0,0 +1,258 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// GetValue returns the value a dotted key such as "providers.openai.base_url"
+// names in cfg, a *Config or *RepoConfig. ok is false when it isn't set;
+// a zero value such as false counts as set when the file or layers that
+// cfg came from spell it out.
+func GetValue(cfg any, key string) (value any, ok bool, err error) {
+	v, err := walk(reflect.ValueOf(cfg).Elem(), splitKey(key), key, nil)
+	if err != nil || !v.IsValid() {
+		return nil, false, err
+	}
+	if v.IsZero() && isEmpty(lookup(rawValues(cfg), splitKey(key))) {
+		return nil, false, nil
+	}
+	return v.Interface(), true, nil
+}
+
+// rawValues returns the values cfg was loaded from, nil for one built in code.
+func rawValues(cfg any) map[string]any {
+	switch c := cfg.(type) {
+	case *Config:
+		return c.raw
+	case *RepoConfig:
+		return c.raw
+	}
+	return nil
+}
+
+// SetValue parses value as the type of the field key names and stores it,
+// creating map entries such as a new provider on the way. Lists may be
+// given as "a,b" or "[a, b]", durations as "30s".
+func SetValue(cfg any, key string, value string) error {
+	if c, ok := cfg.(*Config); ok {
+		c.forgetSecrets(key)
+	}
+	_, err := walk(reflect.ValueOf(cfg).Elem(), splitKey(key), key, &edit{apply: func(v reflect.Value) error {
+		parsed, err := parseValue(v.Type(), value)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not %s", key, value, typeName(v.Type()))
+		}
+		v.Set(parsed)
+		return nil
+	}})
+	return err
+}
+
+// UnsetValue clears key, removing it from its map if it is a map entry.
+func UnsetValue(cfg any, key string) error {
+	if c, ok := cfg.(*Config); ok {
+		c.forgetSecrets(key)
+	}
+	_, err := walk(reflect.ValueOf(cfg).Elem(), splitKey(key), key, &edit{remove: true, apply: func(v reflect.Value) error {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}})
+	return err
+}
+
+// Flatten lists the values set in cfg by dotted key, sorted.
+func Flatten(cfg any) ([]Setting, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	var out []Setting
+	var visit func(prefix string, m map[string]any)
+	visit = func(prefix string, m map[string]any) {
+		for k, v := range m {
+			if sub, ok := v.(map[string]any); ok {
+				visit(prefix+k+".", sub)
+			} else if !isEmpty(v) {
+				out = append(out, Setting{Key: prefix + k, Value: v})
+			}
+		}
+	}
+	visit("", raw)
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out, nil
+}
+
+// SaveRepoConfig writes cfg to .ai-git.yaml in rootPath.
+func SaveRepoConfig(rootPath string, cfg *RepoConfig) error {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(rootPath, ".ai-git.yaml"), data, 0644)
+}
+
+func splitKey(key string) []string {
+	return strings.Split(strings.Trim(key, "."), ".")
+}
+
+// edit is a change walk makes where the path ends; remove deletes map
+// entries instead of calling apply on them.
+type edit struct {
+	apply  func(reflect.Value) error
+	remove bool
+}
+
+// walk follows path from v and applies e where it ends, writing the result
+// back into any map on the way. A nil e only reads: missing map entries
+// then come back as the invalid Value, while writes create them.
+func walk(v reflect.Value, path []string, key string, e *edit) (reflect.Value, error) {
+	if len(path) == 0 {
+		if e == nil {
+			return v, nil
+		}
+		return v, e.apply(v)
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		field, ok := yamlField(v, path[0])
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("unknown key '%s' (valid here: %s)", key, strings.Join(yamlNames(v.Type()), ", "))
+		}
+		return walk(field, path[1:], key, e)
+
+	case reflect.Map:
+		mapKey := reflect.ValueOf(path[0]).Convert(v.Type().Key())
+		existing := v.MapIndex(mapKey)
+		if e != nil && e.remove && len(path) == 1 {
+			if !v.IsNil() {
+				v.SetMapIndex(mapKey, reflect.Value{})
+			}
+			return reflect.Value{}, nil
+		}
+		if e == nil && !existing.IsValid() {
+			return reflect.Value{}, nil
+		}
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if existing.IsValid() {
+			elem.Set(existing)
+		}
+		if elem.Kind() == reflect.Interface && len(path) > 1 {
+			// Nested free-form options, e.g. options.extra.x
+			m, ok := elem.Interface().(map[string]any)
+			if !ok {
+				if e == nil {
+					return reflect.Value{}, nil
+				}
+				m = map[string]any{}
+			}
+			elem = reflect.ValueOf(m)
+		}
+		out, err := walk(elem, path[1:], key, e)
+		if err != nil || e == nil {
+			return out, err
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		v.SetMapIndex(mapKey, elem)
+		return elem, nil
+	}
+	return reflect.Value{}, fmt.Errorf("unknown key '%s'", key)
+}
+
+func yamlField(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tagName, inline := yamlTag(t.Field(i))
+		if inline {
+			if f, ok := yamlField(v.Field(i), name); ok {
+				return f, true
+			}
+			continue
+		}
+		if tagName == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func yamlNames(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		name, inline := yamlTag(t.Field(i))
+		if inline {
+			names = append(names, yamlNames(t.Field(i).Type)...)
+		} else if name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func yamlTag(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("yaml")
+	name, opts, _ := strings.Cut(tag, ",")
+	if strings.Contains(opts, "inline") {
+		return "", true
+	}
+	if name == "" {
+		name = strings.ToLower(f.Name)
+	}
+	return name, false
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func parseValue(t reflect.Type, s string) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	switch {
+	case t.Kind() == reflect.String:
+		v.SetString(s)
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(s), "["):
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				v = reflect.Append(v, reflect.ValueOf(item).Convert(t.Elem()))
+			}
+		}
+	default:
+		if err := yaml.Unmarshal([]byte(s), v.Addr().Interface()); err != nil {
+			return v, err
+		}
+	}
+	return v, nil
+}
+
+func typeName(t reflect.Type) string {
+	if t == durationType {
+		return "a duration such as 30s"
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int64, reflect.Int32:
+		return "an integer"
+	case reflect.Float64, reflect.Float32:
+		return "a number"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Slice:
+		return "a list such as a,b"
+	case reflect.Map, reflect.Struct:
+		return "a mapping such as {a: 1}"
+	case reflect.Pointer:
+		return typeName(t.Elem())
+	}
+	return "a valid value"
+}
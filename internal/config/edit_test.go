@@ -0,0 +1,123 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSetValue(t *testing.T) {
+	tests := []struct {
+		key, value string
+		want       any
+		wantErr    bool
+	}{
+		{key: "default_provider", value: "ollama", want: "ollama"},
+		{key: "providers.openai.base_url", value: "https://example.com/v1", want: "https://example.com/v1"},
+		{key: "providers.openai.custom_models", value: "a,b", want: []string{"a", "b"}},
+		{key: "providers.openai.custom_models", value: "[a, b]", want: []string{"a", "b"}},
+		{key: "providers.openai.max_retries", value: "5", want: 5},
+		{key: "providers.openai.timeout", value: "45s", want: 45 * time.Second},
+		{key: "providers.openai.temperature", value: "0.2", want: 0.2},
+		{key: "providers.openai.headers.X-Org", value: "acme", want: "acme"},
+		{key: "output.structured", value: "true", want: true},
+		{key: "cache.ttl", value: "12h", want: 12 * time.Hour},
+		{key: "output.structured", value: "maybe", wantErr: true},
+		{key: "providers.openai.max_retries", value: "many", wantErr: true},
+		{key: "no_such_key", value: "x", wantErr: true},
+		{key: "output.no_such_key", value: "x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			cfg := &Config{}
+			err := SetValue(cfg, tt.key, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetValue err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got, ok, err := GetValue(cfg, tt.key)
+			if err != nil || !ok {
+				t.Fatalf("GetValue = %v, %v, %v", got, ok, err)
+			}
+			if p, isPtr := got.(*float64); isPtr {
+				got = *p
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetValue = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetValueRepoConfig(t *testing.T) {
+	cfg := &RepoConfig{}
+	if err := SetValue(cfg, "commit_style", "gitmoji"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetValue(cfg, "max_tokens", "200"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.CommitStyle != "gitmoji" || cfg.Generation.MaxTokens != 200 {
+		t.Errorf("got %+v", cfg)
+	}
+}
+
+func TestGetValue(t *testing.T) {
+	cfg := &Config{
+		Providers: map[string]ProviderConfig{"openai": {DefaultModel: "gpt-4o"}},
+		raw: map[string]any{
+			"output":    map[string]any{"structured": false},
+			"providers": map[string]any{"openai": map[string]any{"default_model": "gpt-4o"}},
+		},
+	}
+	tests := []struct {
+		key     string
+		want    any
+		ok      bool
+		wantErr bool
+	}{
+		{key: "providers.openai.default_model", want: "gpt-4o", ok: true},
+		{key: "providers.openai", want: ProviderConfig{DefaultModel: "gpt-4o"}, ok: true},
+		{key: "output.structured", want: false, ok: true}, // written out in the file
+		{key: "output.candidates"},                        // zero and not in the file
+		{key: "providers.missing.default_model"},
+		{key: "output.bogus", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, ok, err := GetValue(cfg, tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetValue = %#v, %v, want %#v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestUnsetValue(t *testing.T) {
+	cfg := &Config{}
+	for _, kv := range [][2]string{{"providers.a.base_url", "x"}, {"providers.b.base_url", "y"}, {"output.language", "german"}} {
+		if err := SetValue(cfg, kv[0], kv[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := UnsetValue(cfg, "providers.a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := UnsetValue(cfg, "output.language"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cfg.Providers["a"]; ok {
+		t.Error("providers.a is still there")
+	}
+	if cfg.Providers["b"].BaseURL != "y" {
+		t.Error("providers.b was lost")
+	}
+	if cfg.Output.Language != "" {
+		t.Errorf("output.language = %q", cfg.Output.Language)
+	}
+}
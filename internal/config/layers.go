@@ -0,0 +1,392 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/eliau2005/ai-git/internal/prompt"
+	"gopkg.in/yaml.v3"
+)
+
+// Configuration layers, lowest precedence first.
+const (
+	LayerDefault = "default"
+	LayerSystem  = "system"
+	LayerGlobal  = "global"
+	LayerRepo    = "repo"
+	LayerEnv     = "env"
+	LayerFlag    = "flag"
+)
+
+// SystemConfigEnv points at the system-wide config file instead of the
+// platform's default location.
+const SystemConfigEnv = "AI_GIT_SYSTEM_CONFIG"
+
+// Variables read by the env layer, and the keys they set. AI_GIT_MODEL
+// sets the active provider's default_model, like model_override.
+var envKeys = []struct{ env, key string }{
+	{"AI_GIT_PROVIDER", "default_provider"},
+	{"AI_GIT_MODEL", "model"},
+	{"AI_GIT_COMMIT_STYLE", "output.style"},
+	{"AI_GIT_LANGUAGE", "output.language"},
+}
+
+// repoKeys maps .ai-git.yaml keys onto the global config's.
+var repoKeys = map[string]string{
+	"enabled_provider":   "default_provider",
+	"model_override":     "model",
+	"commit_style":       "output.style",
+	"language":           "output.language",
+	"max_subject_length": "output.max_subject_length",
+	"types":              "output.types",
+	"fallback_providers": "fallback_providers",
+	"prompts":            "prompts",
+	"tasks":              "tasks",
+}
+
+// generationKeys are the GenerationConfig fields; in .ai-git.yaml they
+// apply to every provider.
+var generationKeys = []string{"temperature", "top_p", "max_tokens", "stop", "context_window", "options"}
+
+// Layer is one source of settings, shaped like config.yaml. The
+// pseudo-key "model" sets the active provider's default_model.
+type Layer struct {
+	Name   string
+	Source string // the file or variables it was read from
+	Values map[string]any
+}
+
+// Setting is an effective value and where it came from.
+type Setting struct {
+	Key    string // dotted path, e.g. providers.openai.default_model
+	Value  any
+	Layer  string
+	Source string
+}
+
+// Resolved is the effective configuration: built-in defaults, the system
+// config, the global config, the repository's .ai-git.yaml, environment
+// variables and command-line flags, each overriding the ones before.
+// Repository settings are folded into the global shape, e.g. commit_style
+// becomes output.style and model_override the active provider's
+// default_model.
+type Resolved struct {
+	*Config
+	Global *Config     // the global config file alone, for editing and saving
+	Repo   *RepoConfig // nil outside a repository or without .ai-git.yaml
+
+	values   map[string]any
+	settings map[string]Setting
+}
+
+// Settings returns every effective value, sorted by key.
+func (r *Resolved) Settings() []Setting {
+	out := make([]Setting, 0, len(r.settings))
+	for _, s := range r.settings {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+// Origin returns the setting for a dotted key.
+func (r *Resolved) Origin(key string) (Setting, bool) {
+	s, ok := r.settings[key]
+	return s, ok
+}
+
+// Resolve loads every layer for the repository at root (may be empty)
+// and merges them, with the flag layers on top, in order. Like
+// LoadConfig, it returns the config with an ErrSecretsLocked error when
+// the secrets store is locked.
+func Resolve(root string, flags ...Layer) (*Resolved, error) {
+	// A locked store is reported at the end, with everything else resolved
+	global, secretsErr := LoadConfig()
+	if secretsErr != nil && !errors.Is(secretsErr, ErrSecretsLocked) {
+		return nil, secretsErr
+	}
+	repo, err := LoadRepoConfig(root)
+	if err != nil {
+		return nil, fmt.Errorf(".ai-git.yaml: %w", err)
+	}
+
+	r := &Resolved{Global: global, Repo: repo, values: map[string]any{}, settings: map[string]Setting{}}
+	r.apply(Layer{Name: LayerDefault, Source: "built-in", Values: map[string]any{
+		"system_prompt":          prompt.DefaultSystem,
+		"commit_prompt_template": prompt.Defaults[prompt.Commit],
+		"output":                 map[string]any{"style": "conventional", "language": "english"},
+	}})
+
+	systemPath := systemConfigPath()
+	system, err := readYAML(systemPath)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", systemPath, err)
+	}
+	r.apply(Layer{Name: LayerSystem, Source: systemPath, Values: system})
+
+	if err := r.applyGlobal(global); err != nil {
+		return nil, err
+	}
+
+	if root != "" {
+		repoPath := filepath.Join(root, ".ai-git.yaml")
+		raw, err := readYAML(repoPath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", repoPath, err)
+		}
+		r.apply(Layer{Name: LayerRepo, Source: repoPath, Values: r.repoValues(raw)})
+	}
+
+	for _, e := range envKeys {
+		if v := os.Getenv(e.env); v != "" {
+			r.apply(Layer{Name: LayerEnv, Source: e.env, Values: nest(e.key, v)})
+		}
+	}
+	for _, l := range flags {
+		r.apply(l)
+	}
+
+	data, err := yaml.Marshal(r.values)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid effective config: %w", err)
+	}
+	if cfg.Providers == nil {
+		cfg.Providers = make(map[string]ProviderConfig)
+	}
+	if cfg.Platforms == nil {
+		cfg.Platforms = make(map[string]PlatformConfig)
+	}
+	cfg.raw = r.values
+	r.Config = &cfg
+	return r, secretsErr
+}
+
+// applyGlobal adds the global config file, with the keys and tokens the
+// encrypted store supplies.
+func (r *Resolved) applyGlobal(global *Config) error {
+	dir, err := configDir()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, "config.yaml")
+	raw, err := readYAML(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	r.apply(Layer{Name: LayerGlobal, Source: path, Values: raw})
+
+	stored := map[string]any{}
+	for name, p := range global.Providers {
+		if p.APIKey != "" && !hasValue(raw, "providers", name, "api_key") {
+			stored[name] = map[string]any{"api_key": p.APIKey}
+		}
+	}
+	platforms := map[string]any{}
+	for name, p := range global.Platforms {
+		if p.Token != "" && !hasValue(raw, "platforms", name, "token") {
+			platforms[name] = map[string]any{"token": p.Token}
+		}
+	}
+	if len(stored) > 0 || len(platforms) > 0 {
+		secretsFile, _ := secretsPath()
+		r.apply(Layer{Name: LayerGlobal, Source: secretsFile, Values: map[string]any{"providers": stored, "platforms": platforms}})
+	}
+	return nil
+}
+
+// repoValues translates .ai-git.yaml into the global shape. A task entry
+// that names a provider replaces the global one rather than merging with it.
+func (r *Resolved) repoValues(raw map[string]any) map[string]any {
+	out := map[string]any{}
+	for key, v := range raw {
+		if target, ok := repoKeys[key]; ok {
+			mergeValues(out, nest(target, v))
+		}
+	}
+	if tasks, ok := out["tasks"].(map[string]any); ok {
+		for name, t := range tasks {
+			if entry, ok := t.(map[string]any); ok && entry["provider"] != nil {
+				r.unset("tasks." + name)
+			}
+		}
+	}
+
+	generation := map[string]any{}
+	for _, key := range generationKeys {
+		if v, ok := raw[key]; ok {
+			generation[key] = v
+		}
+	}
+	if len(generation) > 0 {
+		providers := map[string]any{}
+		if current, ok := r.values["providers"].(map[string]any); ok {
+			for name := range current {
+				providers[name] = generation
+			}
+		}
+		out["providers"] = providers
+	}
+	return out
+}
+
+// apply merges a layer into the effective values and records the origin
+// of every value it sets.
+func (r *Resolved) apply(l Layer) {
+	values := l.Values
+	if model, ok := values["model"]; ok {
+		values = copyMap(values)
+		delete(values, "model")
+		active, _ := values["default_provider"].(string)
+		if active == "" {
+			active, _ = r.values["default_provider"].(string)
+		}
+		if active != "" {
+			mergeValues(values, nest("providers."+active+".default_model", model))
+		}
+	}
+	r.merge(values, nil, l)
+}
+
+func (r *Resolved) merge(src map[string]any, path []string, l Layer) {
+	for key, v := range src {
+		p := append(append([]string(nil), path...), key)
+		if isEmpty(v) {
+			continue
+		}
+		if m, ok := v.(map[string]any); ok {
+			if _, isMap := lookup(r.values, p).(map[string]any); !isMap {
+				r.unset(strings.Join(p, "."))
+			}
+			r.merge(m, p, l)
+			continue
+		}
+		r.unset(strings.Join(p, "."))
+		set(r.values, p, v)
+		dotted := strings.Join(p, ".")
+		r.settings[dotted] = Setting{Key: dotted, Value: v, Layer: l.Name, Source: l.Source}
+	}
+}
+
+// unset removes key and everything under it.
+func (r *Resolved) unset(key string) {
+	p := strings.Split(key, ".")
+	parent, ok := lookup(r.values, p[:len(p)-1]).(map[string]any)
+	if len(p) == 1 {
+		parent, ok = r.values, true
+	}
+	if ok {
+		delete(parent, p[len(p)-1])
+	}
+	for k := range r.settings {
+		if k == key || strings.HasPrefix(k, key+".") {
+			delete(r.settings, k)
+		}
+	}
+}
+
+func readYAML(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+func systemConfigPath() string {
+	if path := os.Getenv(SystemConfigEnv); path != "" {
+		return path
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("ProgramData"), "ai-git", "config.yaml")
+	}
+	return "/etc/ai-git/config.yaml"
+}
+
+// nest turns a dotted key and a value into nested maps.
+func nest(key string, v any) map[string]any {
+	parts := strings.Split(key, ".")
+	out := map[string]any{parts[len(parts)-1]: v}
+	for i := len(parts) - 2; i >= 0; i-- {
+		out = map[string]any{parts[i]: out}
+	}
+	return out
+}
+
+// mergeValues deep-merges src into dst.
+func mergeValues(dst map[string]any, src map[string]any) {
+	for k, v := range src {
+		if m, ok := v.(map[string]any); ok {
+			if d, ok := dst[k].(map[string]any); ok {
+				mergeValues(d, m)
+				continue
+			}
+			v = copyMap(m)
+		}
+		dst[k] = v
+	}
+}
+
+func copyMap(m map[string]any) map[string]any {
+	out := make(map[string]any, len(m))
+	mergeValues(out, m)
+	return out
+}
+
+func lookup(values map[string]any, path []string) any {
+	var cur any = values
+	for _, p := range path {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil
+		}
+		cur = m[p]
+	}
+	return cur
+}
+
+func set(values map[string]any, path []string, v any) {
+	m := values
+	for _, p := range path[:len(path)-1] {
+		next, ok := m[p].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			m[p] = next
+		}
+		m = next
+	}
+	m[path[len(path)-1]] = v
+}
+
+func hasValue(values map[string]any, path ...string) bool {
+	return !isEmpty(lookup(values, path))
+}
+
+// isEmpty reports values that don't override anything: config.yaml holds
+// empty strings for fields it was saved without.
+func isEmpty(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case map[string]any:
+		return len(v) == 0
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setupLayers writes the system, global and repository files into temp
+// directories and clears the env layer. It returns the repository root.
+func setupLayers(t *testing.T, system, global, repo string) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, e := range envKeys {
+		t.Setenv(e.env, "")
+	}
+	t.Setenv(PassphraseEnv, "")
+
+	write := func(path, data string) {
+		if data == "" {
+			return
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	systemPath := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv(SystemConfigEnv, systemPath)
+	write(systemPath, system)
+	write(filepath.Join(home, ".config", "ai-git", "config.yaml"), global)
+
+	root := t.TempDir()
+	write(filepath.Join(root, ".ai-git.yaml"), repo)
+	return root
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name                 string
+		system, global, repo string
+		env                  map[string]string
+		flags                []Layer
+		key                  string
+		want                 any
+		layer                string
+	}{
+		{
+			name:  "built-in default",
+			key:   "output.style",
+			want:  "conventional",
+			layer: LayerDefault,
+		},
+		{
+			name:   "system over default",
+			system: "output:\n  language: french\n",
+			key:    "output.language",
+			want:   "french",
+			layer:  LayerSystem,
+		},
+		{
+			name:   "global over system",
+			system: "output:\n  language: french\n",
+			global: "output:\n  language: german\n",
+			key:    "output.language",
+			want:   "german",
+			layer:  LayerGlobal,
+		},
+		{
+			name:   "repo commit_style becomes output.style",
+			global: "output:\n  style: plain\n",
+			repo:   "commit_style: gitmoji\n",
+			key:    "output.style",
+			want:   "gitmoji",
+			layer:  LayerRepo,
+		},
+		{
+			name:   "repo model_override sets the active provider's model",
+			global: "default_provider: openai\nproviders:\n  openai:\n    default_model: gpt-4o\n",
+			repo:   "model_override: gpt-4o-mini\n",
+			key:    "providers.openai.default_model",
+			want:   "gpt-4o-mini",
+			layer:  LayerRepo,
+		},
+		{
+			name:   "repo generation settings apply to every provider",
+			global: "providers:\n  openai:\n    default_model: gpt-4o\n  ollama:\n    default_model: llama3\n",
+			repo:   "max_tokens: 300\n",
+			key:    "providers.ollama.max_tokens",
+			want:   300,
+			layer:  LayerRepo,
+		},
+		{
+			name:  "env over repo",
+			repo:  "language: spanish\n",
+			env:   map[string]string{"AI_GIT_LANGUAGE": "italian"},
+			key:   "output.language",
+			want:  "italian",
+			layer: LayerEnv,
+		},
+		{
+			name:  "flag over env",
+			env:   map[string]string{"AI_GIT_PROVIDER": "ollama"},
+			flags: []Layer{{Name: LayerFlag, Source: "--provider", Values: map[string]any{"default_provider": "anthropic"}}},
+			key:   "default_provider",
+			want:  "anthropic",
+			layer: LayerFlag,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := setupLayers(t, tt.system, tt.global, tt.repo)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			res, err := Resolve(root, tt.flags...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok, err := GetValue(res.Config, tt.key)
+			if err != nil || !ok || got != tt.want {
+				t.Errorf("%s = %#v, %v, %v, want %#v", tt.key, got, ok, err, tt.want)
+			}
+			s, ok := res.Origin(tt.key)
+			if !ok || s.Layer != tt.layer {
+				t.Errorf("%s comes from %q, want %q", tt.key, s.Layer, tt.layer)
+			}
+		})
+	}
+}
+
+func TestResolveRepoTaskReplacesGlobal(t *testing.T) {
+	root := setupLayers(t, "",
+		"tasks:\n  pr:\n    provider: openai\n    model: gpt-4o\n",
+		"tasks:\n  pr:\n    provider: ollama\n")
+	res, err := Resolve(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.Tasks["pr"]; got != (TaskConfig{Provider: "ollama"}) {
+		t.Errorf("tasks.pr = %+v, want the repository's entry alone", got)
+	}
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// standardKeyEnv lists the conventional variables each provider's own SDKs
+// read. They only apply to the entry named after the provider, so a key
+// for one service is never sent to another endpoint.
+var standardKeyEnv = map[string]string{
+	"openai":    "OPENAI_API_KEY",
+	"anthropic": "ANTHROPIC_API_KEY",
+	"gemini":    "GEMINI_API_KEY",
+}
+
+var standardTokenEnv = map[string]string{
+	"github": "GITHUB_TOKEN",
+	"gitlab": "GITLAB_TOKEN",
+}
+
+var envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Command output is cached so a password manager is asked only once per run.
+var (
+	secretCacheMu sync.Mutex
+	secretCache   = map[string]string{}
+)
+
+// ResolveAPIKey returns the key for the provider entry called name:
+// AI_GIT_<NAME>_API_KEY if set, else the configured value with references
+// resolved (see ResolveSecret), else the provider's standard variable.
+func (p ProviderConfig) ResolveAPIKey(name string) (string, error) {
+	return resolveCredential(p.APIKey, envName(name, "API_KEY"), standardKeyEnv[name])
+}
+
+// ResolveToken does the same for a platform token, with AI_GIT_<NAME>_TOKEN
+// and e.g. GITHUB_TOKEN.
+func (p PlatformConfig) ResolveToken(name string) (string, error) {
+	return resolveCredential(p.Token, envName(name, "TOKEN"), standardTokenEnv[name])
+}
+
+// WithSecrets returns a copy of p with its API key and header values resolved.
+func (p ProviderConfig) WithSecrets(name string) (ProviderConfig, error) {
+	key, err := p.ResolveAPIKey(name)
+	if err != nil {
+		return p, fmt.Errorf("provider %s: api_key: %w", name, err)
+	}
+	p.APIKey = key
+	if len(p.Headers) > 0 {
+		headers := make(map[string]string, len(p.Headers))
+		for k, v := range p.Headers {
+			if headers[k], err = ResolveSecret(v); err != nil {
+				return p, fmt.Errorf("provider %s: header %s: %w", name, k, err)
+			}
+		}
+		p.Headers = headers
+	}
+	return p, nil
+}
+
+func resolveCredential(value string, override string, standard string) (string, error) {
+	if v := os.Getenv(override); v != "" {
+		return v, nil
+	}
+	if value != "" {
+		return ResolveSecret(value)
+	}
+	if standard != "" {
+		return os.Getenv(standard), nil
+	}
+	return "", nil
+}
+
+// ResolveSecret expands a configured secret. "cmd: <command>" runs the
+// command through the shell and uses its trimmed output, e.g.
+// "cmd: pass show openai"; ${VAR} references are replaced with the
+// variable's value. Anything else is returned unchanged.
+func ResolveSecret(value string) (string, error) {
+	if command, ok := strings.CutPrefix(value, "cmd:"); ok {
+		return runSecretCommand(strings.TrimSpace(command))
+	}
+	var missing []string
+	out := envRef.ReplaceAllStringFunc(value, func(ref string) string {
+		name := envRef.FindStringSubmatch(ref)[1]
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// IsSecretRef reports whether value refers to a secret instead of holding it.
+func IsSecretRef(value string) bool {
+	return strings.HasPrefix(value, "cmd:") || envRef.MatchString(value)
+}
+
+func runSecretCommand(command string) (string, error) {
+	secretCacheMu.Lock()
+	defer secretCacheMu.Unlock()
+	if v, ok := secretCache[command]; ok {
+		return v, nil
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Stdin = os.Stdin // password managers may prompt
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("`%s` failed: %w", command, err)
+	}
+	v := strings.TrimSpace(string(out))
+	if v == "" {
+		return "", fmt.Errorf("`%s` printed nothing", command)
+	}
+	secretCache[command] = v
+	return v, nil
+}
+
+// envName builds AI_GIT_<NAME>_<SUFFIX>, with anything but letters and
+// digits in name turned into underscores.
+func envName(name string, suffix string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToUpper(name) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune('_')
+		}
+	}
+	return "AI_GIT_" + sb.String() + "_" + suffix
+}
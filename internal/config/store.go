@@ -0,0 +1,499 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// API keys and platform tokens are kept out of config.yaml, in secrets.enc,
+// encrypted with AES-256-GCM. The key is either read from a local key file,
+// created on first use, or derived from a passphrase.
+const (
+	kdfKeyFile = "keyfile"
+	kdfPBKDF2  = "pbkdf2-sha256"
+
+	pbkdf2Iterations = 600000
+	secretsVersion   = 1
+)
+
+// PassphraseEnv holds the passphrase of a passphrase-protected store.
+const PassphraseEnv = "AI_GIT_PASSPHRASE"
+
+// ErrSecretsLocked is wrapped by the errors of a store that can't be
+// unlocked. LoadConfig and Resolve still return the config with it, just
+// without the store's keys and tokens, for commands that don't need them.
+var ErrSecretsLocked = errors.New("secrets are locked")
+
+// PassphrasePrompt asks for the store's passphrase when PassphraseEnv is
+// unset. Without it, a passphrase-protected store can't be unlocked.
+var PassphrasePrompt func() (string, error)
+
+type secretsFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations,omitempty"`
+	Salt       []byte `json:"salt,omitempty"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+type secrets struct {
+	Providers map[string]string `json:"providers,omitempty"` // API keys
+	Platforms map[string]string `json:"platforms,omitempty"` // tokens
+}
+
+// storeKey is the unlocked key, kept for the rest of the run so the
+// passphrase is asked for at most once.
+type storeKey struct {
+	kdf        string
+	iterations int
+	salt       []byte
+	key        []byte
+}
+
+// The key, the decrypted store and a failure to unlock it are all kept for
+// the life of the process: config is loaded many times per command, and
+// a wrong or missing passphrase shouldn't be asked for again each time.
+var (
+	storeKeyMu sync.Mutex
+	unlocked   *storeKey
+	stored     *secrets
+	unlockErr  error
+)
+
+func configDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "ai-git"), nil
+}
+
+func secretsPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "secrets.enc"), nil
+}
+
+func keyFilePath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "secret.key"), nil
+}
+
+// SecretsMode describes how the secrets store is protected: "key file",
+// "passphrase", or "" when there is no store yet.
+func SecretsMode() (string, error) {
+	f, err := readSecretsFile()
+	if err != nil || f == nil {
+		return "", err
+	}
+	if f.KDF == kdfPBKDF2 {
+		return "passphrase", nil
+	}
+	return "key file", nil
+}
+
+// PlaintextSecrets lists the entries of config.yaml that still hold an
+// API key or token in clear text, e.g. "providers.openai".
+func PlaintextSecrets() ([]string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var raw Config
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	s := collectSecrets(&raw)
+	var names []string
+	for name := range s.Providers {
+		names = append(names, "providers."+name)
+	}
+	for name := range s.Platforms {
+		names = append(names, "platforms."+name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// MigrateSecrets moves the clear-text keys and tokens of config.yaml into
+// the encrypted store and returns how many were moved. A non-empty
+// passphrase (re-)encrypts the store with it; otherwise an existing store
+// keeps its protection and a new one uses the key file.
+func MigrateSecrets(passphrase string) (int, error) {
+	plain, err := PlaintextSecrets()
+	if err != nil {
+		return 0, err
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		return 0, err
+	}
+	if passphrase != "" {
+		if err := usePassphrase(passphrase); err != nil {
+			return 0, err
+		}
+	}
+	if err := cfg.saveSecrets(passphrase != ""); err != nil {
+		return 0, err
+	}
+	if err := cfg.Save(); err != nil {
+		return 0, err
+	}
+	return len(plain), nil
+}
+
+// collectSecrets gathers the keys and tokens that belong in the store;
+// references to environment variables and commands stay in the config.
+func collectSecrets(cfg *Config) secrets {
+	s := secrets{Providers: map[string]string{}, Platforms: map[string]string{}}
+	for name, p := range cfg.Providers {
+		if p.APIKey != "" && !IsSecretRef(p.APIKey) {
+			s.Providers[name] = p.APIKey
+		}
+	}
+	for name, p := range cfg.Platforms {
+		if p.Token != "" && !IsSecretRef(p.Token) {
+			s.Platforms[name] = p.Token
+		}
+	}
+	return s
+}
+
+// forgetSecrets marks the store entries an explicit unset of key removes,
+// so the next Save deletes them: "providers" or "platforms" drops them
+// all, "providers.<name>" and "providers.<name>.api_key" one.
+func (cfg *Config) forgetSecrets(key string) {
+	parts := splitKey(key)
+	field := map[string]string{"providers": "api_key", "platforms": "token"}[parts[0]]
+	if field == "" || len(parts) > 3 || (len(parts) == 3 && parts[2] != field) {
+		return
+	}
+	if cfg.dropped == nil {
+		cfg.dropped = make(map[string]bool)
+	}
+	if len(parts) == 1 {
+		cfg.dropped[parts[0]] = true
+	} else {
+		cfg.dropped[parts[0]+"."+parts[1]] = true
+	}
+}
+
+// applySecrets fills in the keys and tokens the config file leaves empty.
+func (cfg *Config) applySecrets() error {
+	s, err := loadSecrets()
+	if err != nil || s == nil {
+		return err
+	}
+	for name, key := range s.Providers {
+		if p, ok := cfg.Providers[name]; ok && p.APIKey == "" {
+			p.APIKey = key
+			cfg.Providers[name] = p
+		}
+	}
+	for name, token := range s.Platforms {
+		if p, ok := cfg.Platforms[name]; ok && p.Token == "" {
+			p.Token = token
+			cfg.Platforms[name] = p
+		}
+	}
+	return nil
+}
+
+// withoutSecrets returns a copy of cfg for config.yaml, with the values
+// kept in the store left out.
+func (cfg *Config) withoutSecrets() *Config {
+	out := *cfg
+	out.Providers = make(map[string]ProviderConfig, len(cfg.Providers))
+	for name, p := range cfg.Providers {
+		if !IsSecretRef(p.APIKey) {
+			p.APIKey = ""
+		}
+		out.Providers[name] = p
+	}
+	out.Platforms = make(map[string]PlatformConfig, len(cfg.Platforms))
+	for name, p := range cfg.Platforms {
+		if !IsSecretRef(p.Token) {
+			p.Token = ""
+		}
+		out.Platforms[name] = p
+	}
+	return &out
+}
+
+// saveSecrets writes cfg's keys and tokens into the store. Entries cfg
+// doesn't mention are kept unless an unset dropped them. No store is
+// created while there is nothing to keep in it, unless force is set.
+func (cfg *Config) saveSecrets(force bool) error {
+	path, err := secretsPath()
+	if err != nil {
+		return err
+	}
+	s := collectSecrets(cfg)
+	existing, err := loadSecrets()
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		for name, key := range existing.Providers {
+			if _, ok := s.Providers[name]; !ok && !cfg.dropped["providers"] && !cfg.dropped["providers."+name] {
+				s.Providers[name] = key
+			}
+		}
+		for name, token := range existing.Platforms {
+			if _, ok := s.Platforms[name]; !ok && !cfg.dropped["platforms"] && !cfg.dropped["platforms."+name] {
+				s.Platforms[name] = token
+			}
+		}
+	}
+	if len(s.Providers) == 0 && len(s.Platforms) == 0 && !force && existing == nil {
+		return nil
+	}
+
+	k, err := writeKey()
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(k.key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(secretsFile{
+		Version:    secretsVersion,
+		KDF:        k.kdf,
+		Iterations: k.iterations,
+		Salt:       k.salt,
+		Nonce:      nonce,
+		Data:       gcm.Seal(nil, nonce, plain, nil),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	storeKeyMu.Lock()
+	stored = &s
+	storeKeyMu.Unlock()
+	cfg.dropped = nil
+	return nil
+}
+
+func readSecretsFile() (*secretsFile, error) {
+	path, err := secretsPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var f secretsFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s is corrupt: %w", path, err)
+	}
+	if f.Version != secretsVersion {
+		return nil, fmt.Errorf("%s has unsupported version %d", path, f.Version)
+	}
+	return &f, nil
+}
+
+// loadSecrets returns the store's contents, or nil without a store.
+func loadSecrets() (*secrets, error) {
+	storeKeyMu.Lock()
+	s, err := stored, unlockErr
+	storeKeyMu.Unlock()
+	if s != nil || err != nil {
+		return s, err
+	}
+
+	f, err := readSecretsFile()
+	if err != nil || f == nil {
+		return nil, err
+	}
+	k, err := unlock(f)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(k.key)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, f.Nonce, f.Data, nil)
+	if err != nil {
+		err = fmt.Errorf("%w: the key file doesn't match", ErrSecretsLocked)
+		if f.KDF == kdfPBKDF2 {
+			err = fmt.Errorf("%w: wrong passphrase", ErrSecretsLocked)
+		}
+		storeKeyMu.Lock()
+		unlocked, unlockErr = nil, err
+		storeKeyMu.Unlock()
+		return nil, err
+	}
+	s = &secrets{}
+	if err := json.Unmarshal(plain, s); err != nil {
+		return nil, err
+	}
+	storeKeyMu.Lock()
+	stored = s
+	storeKeyMu.Unlock()
+	return s, nil
+}
+
+// unlock returns the key for f, reading the key file or asking for the
+// passphrase unless it's already known.
+func unlock(f *secretsFile) (*storeKey, error) {
+	storeKeyMu.Lock()
+	defer storeKeyMu.Unlock()
+	if unlockErr != nil {
+		return nil, unlockErr
+	}
+	if unlocked != nil && unlocked.kdf == f.KDF && string(unlocked.salt) == string(f.Salt) {
+		return unlocked, nil
+	}
+
+	var k *storeKey
+	switch f.KDF {
+	case kdfKeyFile:
+		path, err := keyFilePath()
+		if err != nil {
+			return nil, err
+		}
+		key, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: cannot read the key file: %v", ErrSecretsLocked, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("%s is not a valid key file", path)
+		}
+		k = &storeKey{kdf: kdfKeyFile, key: key}
+	case kdfPBKDF2:
+		passphrase := os.Getenv(PassphraseEnv)
+		if passphrase == "" {
+			if PassphrasePrompt == nil {
+				return nil, fmt.Errorf("%w: set %s", ErrSecretsLocked, PassphraseEnv)
+			}
+			var err error
+			if passphrase, err = PassphrasePrompt(); err != nil {
+				unlockErr = fmt.Errorf("%w: %v", ErrSecretsLocked, err)
+				return nil, unlockErr
+			}
+		}
+		key, err := pbkdf2.Key(sha256.New, passphrase, f.Salt, f.Iterations, 32)
+		if err != nil {
+			return nil, err
+		}
+		k = &storeKey{kdf: kdfPBKDF2, iterations: f.Iterations, salt: f.Salt, key: key}
+	default:
+		return nil, fmt.Errorf("unknown secrets encryption '%s'", f.KDF)
+	}
+	unlocked = k
+	return k, nil
+}
+
+// writeKey returns the key to encrypt the store with: the one chosen or
+// unlocked during this run, else the existing store's, else a new key file.
+func writeKey() (*storeKey, error) {
+	storeKeyMu.Lock()
+	k := unlocked
+	storeKeyMu.Unlock()
+	if k != nil {
+		return k, nil
+	}
+	f, err := readSecretsFile()
+	if err != nil {
+		return nil, err
+	}
+	if f != nil {
+		return unlock(f)
+	}
+
+	storeKeyMu.Lock()
+	defer storeKeyMu.Unlock()
+	path, err := keyFilePath()
+	if err != nil {
+		return nil, err
+	}
+	key, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, key, 0600); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	} else if len(key) != 32 {
+		return nil, fmt.Errorf("%s is not a valid key file", path)
+	}
+	unlocked = &storeKey{kdf: kdfKeyFile, key: key}
+	return unlocked, nil
+}
+
+// usePassphrase makes the next write encrypt the store with passphrase.
+func usePassphrase(passphrase string) error {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, pbkdf2Iterations, 32)
+	if err != nil {
+		return err
+	}
+	storeKeyMu.Lock()
+	unlocked = &storeKey{kdf: kdfPBKDF2, iterations: pbkdf2Iterations, salt: salt, key: key}
+	unlockErr = nil
+	storeKeyMu.Unlock()
+	return nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
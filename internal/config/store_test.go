@@ -0,0 +1,84 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestLockedSecrets checks that a store that can't be unlocked only costs
+// the keys it holds: the rest of the config still loads, and nothing is
+// written over the store.
+func TestLockedSecrets(t *testing.T) {
+	root := setupLayers(t, "", "default_provider: openai\nproviders:\n  openai:\n    default_model: gpt-4o\n", "")
+	forget := func() {
+		storeKeyMu.Lock()
+		unlocked, stored, unlockErr = nil, nil, nil
+		storeKeyMu.Unlock()
+	}
+	forget()
+	t.Cleanup(forget)
+	prompt := PassphrasePrompt
+	PassphrasePrompt = nil
+	t.Cleanup(func() { PassphrasePrompt = prompt })
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := cfg.Providers["openai"]
+	p.APIKey = "sk-test"
+	cfg.Providers["openai"] = p
+	if err := usePassphrase("hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+	secrets, _ := secretsPath()
+	before, err := os.ReadFile(secrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A later run without the passphrase
+	forget()
+	cfg, err = LoadConfig()
+	if !errors.Is(err, ErrSecretsLocked) || !strings.Contains(err.Error(), PassphraseEnv) {
+		t.Fatalf("LoadConfig error = %v, want the store locked", err)
+	}
+	if cfg == nil || cfg.Providers["openai"].DefaultModel != "gpt-4o" || cfg.Providers["openai"].APIKey != "" {
+		t.Fatalf("LoadConfig = %+v, want the config without the key", cfg)
+	}
+	if v, ok, err := GetValue(cfg, "providers.openai.default_model"); err != nil || !ok || v != "gpt-4o" {
+		t.Errorf("GetValue = %v, %v, %v", v, ok, err)
+	}
+	r, err := Resolve(root)
+	if !errors.Is(err, ErrSecretsLocked) || r == nil || r.Config.DefaultProvider != "openai" {
+		t.Errorf("Resolve = %v, %v, want the config and the store locked", r, err)
+	}
+	if err := cfg.Save(); !errors.Is(err, ErrSecretsLocked) {
+		t.Errorf("saving with the store locked got %v", err)
+	}
+	if after, _ := os.ReadFile(secrets); string(after) != string(before) {
+		t.Error("the locked store was rewritten")
+	}
+	if data, _ := os.ReadFile(filepath.Join(os.Getenv("HOME"), ".config", "ai-git", "config.yaml")); strings.Contains(string(data), "sk-test") {
+		t.Error("the key leaked into config.yaml")
+	}
+
+	forget()
+	t.Setenv(PassphraseEnv, "wrong")
+	if _, err := LoadConfig(); !errors.Is(err, ErrSecretsLocked) || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Errorf("a wrong passphrase got %v", err)
+	}
+
+	forget()
+	t.Setenv(PassphraseEnv, "hunter2")
+	cfg, err = LoadConfig()
+	if err != nil || cfg.Providers["openai"].APIKey != "sk-test" {
+		t.Errorf("unlocked, LoadConfig = %+v, %v", cfg, err)
+	}
+}
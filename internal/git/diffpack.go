@@ -0,0 +1,285 @@
+package git
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// FileDiff is one file's section of a unified diff.
+type FileDiff struct {
+	Path    string
+	OldPath string   // set for renames
+	Header  string   // "diff --git" line through "+++", without hunks
+	Hunks   []string // each starts with an "@@" line
+	Added   int
+	Deleted int
+	Binary  bool
+}
+
+// Size returns the length of the file's full diff text.
+func (f FileDiff) Size() int {
+	n := len(f.Header)
+	for _, h := range f.Hunks {
+		n += len(h)
+	}
+	return n
+}
+
+// StatLine renders the file like a `git diff --stat` row.
+func (f FileDiff) StatLine() string {
+	name := f.Path
+	if f.OldPath != "" && f.OldPath != f.Path {
+		name = fmt.Sprintf("%s => %s", f.OldPath, f.Path)
+	}
+	if f.Binary {
+		return fmt.Sprintf(" %s | Bin", name)
+	}
+	return fmt.Sprintf(" %s | +%d -%d", name, f.Added, f.Deleted)
+}
+
+// ParseDiff splits a unified diff (as produced by `git diff`) into files and hunks.
+func ParseDiff(diff string) []FileDiff {
+	var files []FileDiff
+	var cur *FileDiff
+	var header, hunk strings.Builder
+
+	flushHunk := func() {
+		if cur != nil && hunk.Len() > 0 {
+			cur.Hunks = append(cur.Hunks, hunk.String())
+			hunk.Reset()
+		}
+	}
+	flushFile := func() {
+		if cur == nil {
+			return
+		}
+		flushHunk()
+		cur.Header = header.String()
+		header.Reset()
+		files = append(files, *cur)
+		cur = nil
+	}
+
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		if line == "" {
+			continue
+		}
+		trimmed := strings.TrimRight(line, "\n")
+
+		if strings.HasPrefix(trimmed, "diff --git ") {
+			flushFile()
+			cur = &FileDiff{Path: samePath(strings.TrimPrefix(trimmed, "diff --git "))}
+			header.WriteString(line)
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "@@") {
+			flushHunk()
+			hunk.WriteString(line)
+			continue
+		}
+		if hunk.Len() > 0 {
+			hunk.WriteString(line)
+			if strings.HasPrefix(trimmed, "+") {
+				cur.Added++
+			} else if strings.HasPrefix(trimmed, "-") {
+				cur.Deleted++
+			}
+			continue
+		}
+
+		header.WriteString(line)
+		switch {
+		case strings.HasPrefix(trimmed, "+++ b/"):
+			// git ends names that contain spaces with a tab
+			cur.Path = strings.TrimSuffix(strings.TrimPrefix(trimmed, "+++ b/"), "\t")
+		case strings.HasPrefix(trimmed, "--- a/"):
+			cur.OldPath = strings.TrimSuffix(strings.TrimPrefix(trimmed, "--- a/"), "\t")
+		case strings.HasPrefix(trimmed, "rename from "):
+			cur.OldPath = strings.TrimPrefix(trimmed, "rename from ")
+		case strings.HasPrefix(trimmed, "rename to "):
+			cur.Path = strings.TrimPrefix(trimmed, "rename to ")
+		case strings.HasPrefix(trimmed, "Binary files "), strings.HasPrefix(trimmed, "GIT binary patch"):
+			cur.Binary = true
+		}
+	}
+	flushFile()
+
+	for i := range files {
+		if files[i].OldPath == files[i].Path {
+			files[i].OldPath = ""
+		}
+	}
+	return files
+}
+
+// samePath returns p from a "diff --git" line of the form "a/p b/p". Paths
+// may contain " b/", so only this symmetric form is unambiguous; the
+// ---, +++ and rename lines name the files otherwise.
+func samePath(s string) string {
+	if len(s)%2 == 0 {
+		return ""
+	}
+	a, b := s[:len(s)/2], s[len(s)/2+1:]
+	if strings.HasPrefix(a, "a/") && strings.HasPrefix(b, "b/") && a[2:] == b[2:] {
+		return a[2:]
+	}
+	return ""
+}
+
+// DiffStat renders a --stat style summary of every file in the diff.
+func DiffStat(files []FileDiff) string {
+	var sb strings.Builder
+	var added, deleted int
+	for _, f := range files {
+		sb.WriteString(f.StatLine() + "\n")
+		added += f.Added
+		deleted += f.Deleted
+	}
+	sb.WriteString(fmt.Sprintf(" %d files changed, %d insertions(+), %d deletions(-)\n", len(files), added, deleted))
+	return sb.String()
+}
+
+// EstimateTokens is a rough, provider-agnostic token count (~4 bytes per token).
+func EstimateTokens(text string) int {
+	return len(text) / 4
+}
+
+// PackDiff fits a unified diff into budgetTokens without cutting mid-hunk.
+// The result always opens with a --stat summary of every changed file.
+// Files are then admitted smallest-first with full hunks; a file that no
+// longer fits keeps its header plus as many whole hunks as remain in the
+// budget. complete reports whether every hunk made it in.
+func PackDiff(diff string, budgetTokens int) (packed string, complete bool) {
+	files := ParseDiff(diff)
+	if len(files) == 0 {
+		return diff, true
+	}
+
+	stat := "Changed files:\n" + DiffStat(files) + "\n"
+	remaining := budgetTokens*4 - len(stat)
+
+	// Decide what to keep, preferring many small files over one large one
+	order := make([]int, len(files))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return files[order[a]].Size() < files[order[b]].Size()
+	})
+
+	keep := make([][]bool, len(files))
+	showHeader := make([]bool, len(files))
+	complete = true
+	for _, i := range order {
+		f := files[i]
+		keep[i] = make([]bool, len(f.Hunks))
+		if len(f.Header) > remaining {
+			complete = complete && len(f.Hunks) == 0
+			continue
+		}
+		showHeader[i] = true
+		remaining -= len(f.Header)
+		for h, hunk := range f.Hunks {
+			if len(hunk) <= remaining {
+				keep[i][h] = true
+				remaining -= len(hunk)
+			} else {
+				complete = false
+			}
+		}
+	}
+
+	if complete {
+		return stat + diff, true
+	}
+
+	var sb strings.Builder
+	sb.WriteString(stat)
+	for i, f := range files {
+		if !showHeader[i] {
+			continue
+		}
+		sb.WriteString(f.Header)
+		omitted := 0
+		for h, hunk := range f.Hunks {
+			if keep[i][h] {
+				sb.WriteString(hunk)
+			} else {
+				omitted++
+			}
+		}
+		if omitted > 0 {
+			sb.WriteString(fmt.Sprintf("... [%d of %d hunks omitted to fit the token budget] ...\n", omitted, len(f.Hunks)))
+		}
+	}
+
+	var dropped []string
+	for i, f := range files {
+		if !showHeader[i] {
+			dropped = append(dropped, f.Path)
+		}
+	}
+	if len(dropped) > 0 {
+		sb.WriteString(fmt.Sprintf("... [diff omitted for %d files, see summary above: %s] ...\n", len(dropped), strings.Join(dropped, ", ")))
+	}
+
+	return sb.String(), false
+}
+
+// GroupDiff splits the files of a diff into chunks of at most budgetTokens
+// each, for summarizing a diff piecewise. Files stay whole where possible;
+// a file larger than the budget is split between hunks, repeating its
+// header in every part.
+func GroupDiff(files []FileDiff, budgetTokens int) []string {
+	limit := budgetTokens * 4
+	var groups []string
+	var cur strings.Builder
+
+	for _, f := range files {
+		text := f.Header + strings.Join(f.Hunks, "")
+		if len(text) > limit {
+			part := f.Header
+			for _, hunk := range f.Hunks {
+				// A single hunk bigger than the whole budget is the one case we must cut
+				hunk = truncateHunk(hunk, limit-len(f.Header))
+				if len(part)+len(hunk) > limit && part != f.Header {
+					groups = append(groups, part)
+					part = f.Header
+				}
+				part += hunk
+			}
+			groups = append(groups, part)
+			continue
+		}
+		if cur.Len()+len(text) > limit && cur.Len() > 0 {
+			groups = append(groups, cur.String())
+			cur.Reset()
+		}
+		cur.WriteString(text)
+	}
+	if cur.Len() > 0 {
+		groups = append(groups, cur.String())
+	}
+	return groups
+}
+
+const truncatedMarker = "\n... [hunk truncated] ...\n"
+
+// truncateHunk shortens hunk to at most limit bytes, marker included,
+// without splitting a UTF-8 character.
+func truncateHunk(hunk string, limit int) string {
+	if len(hunk) <= limit {
+		return hunk
+	}
+	cut := max(limit-len(truncatedMarker), 0)
+	for cut > 0 && !utf8.RuneStart(hunk[cut]) {
+		cut--
+	}
+	return hunk[:cut] + truncatedMarker
+}
@@ -0,0 +1,190 @@
+package git
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+const twoFileDiff = `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,4 @@
+ package main
++import "fmt"
+-var x = 1
++var x = 2
+@@ -10,2 +11,3 @@ func main() {
++	fmt.Println(x)
+ }
+diff --git a/README.md b/README.md
+index 3333333..4444444 100644
+--- a/README.md
++++ b/README.md
+@@ -1 +1 @@
+-old
++new
+`
+
+func TestParseDiff(t *testing.T) {
+	tests := []struct {
+		name  string
+		diff  string
+		want  []FileDiff // without Header and Hunks
+		hunks []int      // hunks per file, if checked
+	}{
+		{
+			name:  "two files",
+			diff:  twoFileDiff,
+			want:  []FileDiff{{Path: "main.go", Added: 3, Deleted: 1}, {Path: "README.md", Added: 1, Deleted: 1}},
+			hunks: []int{2, 1},
+		},
+		{
+			name: "spaces in the path",
+			diff: "diff --git a/my file.txt b/my file.txt\n--- a/my file.txt\t\n+++ b/my file.txt\t\n@@ -1 +1 @@\n-a\n+b\n",
+			want: []FileDiff{{Path: "my file.txt", Added: 1, Deleted: 1}},
+		},
+		{
+			name: "path containing b/",
+			diff: "diff --git a/x b/y b/x b/y\n--- a/x b/y\n+++ b/x b/y\n@@ -1 +1 @@\n-a\n+b\n",
+			want: []FileDiff{{Path: "x b/y", Added: 1, Deleted: 1}},
+		},
+		{
+			name: "rename",
+			diff: "diff --git a/old.go b/new.go\nsimilarity index 90%\nrename from old.go\nrename to new.go\n@@ -1 +1 @@\n-a\n+b\n",
+			want: []FileDiff{{Path: "new.go", OldPath: "old.go", Added: 1, Deleted: 1}},
+		},
+		{
+			name: "binary",
+			diff: "diff --git a/logo.png b/logo.png\nindex 1..2 100644\nBinary files a/logo.png and b/logo.png differ\n",
+			want: []FileDiff{{Path: "logo.png", Binary: true}},
+		},
+		{
+			name: "new file",
+			diff: "diff --git a/new.txt b/new.txt\nnew file mode 100644\n--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+			want: []FileDiff{{Path: "new.txt", Added: 2}},
+		},
+		{
+			name: "empty",
+			diff: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseDiff(tt.diff)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d files, want %d", len(got), len(tt.want))
+			}
+			for i, w := range tt.want {
+				g := got[i]
+				if g.Path != w.Path || g.OldPath != w.OldPath || g.Added != w.Added || g.Deleted != w.Deleted || g.Binary != w.Binary {
+					t.Errorf("file %d = %+v, want %+v", i, g, w)
+				}
+				if !strings.HasPrefix(g.Header, "diff --git ") {
+					t.Errorf("file %d header = %q", i, g.Header)
+				}
+				if tt.hunks != nil && len(g.Hunks) != tt.hunks[i] {
+					t.Errorf("file %d has %d hunks, want %d", i, len(g.Hunks), tt.hunks[i])
+				}
+			}
+			// Nothing is lost in the split
+			var whole strings.Builder
+			for _, f := range got {
+				whole.WriteString(f.Header + strings.Join(f.Hunks, ""))
+			}
+			if whole.String() != tt.diff {
+				t.Errorf("files don't add up to the diff:\n%s", whole.String())
+			}
+		})
+	}
+}
+
+func TestPackDiff(t *testing.T) {
+	tests := []struct {
+		name         string
+		budget       int
+		wantComplete bool
+		contains     []string
+		omits        []string
+	}{
+		{
+			name:         "fits",
+			budget:       10000,
+			wantComplete: true,
+			contains:     []string{"Changed files:", "main.go | +3 -1", twoFileDiff},
+		},
+		{
+			name:     "drops hunks of the larger file",
+			budget:   100,
+			contains: []string{"Changed files:", "+new", "hunks omitted to fit the token budget"},
+			omits:    []string{"fmt.Println"},
+		},
+		{
+			name:     "drops whole files",
+			budget:   40,
+			contains: []string{"Changed files:", "diff omitted for"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packed, complete := PackDiff(twoFileDiff, tt.budget)
+			if complete != tt.wantComplete {
+				t.Errorf("complete = %v, want %v", complete, tt.wantComplete)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(packed, s) {
+					t.Errorf("packed diff lacks %q:\n%s", s, packed)
+				}
+			}
+			for _, s := range tt.omits {
+				if strings.Contains(packed, s) {
+					t.Errorf("packed diff has %q:\n%s", s, packed)
+				}
+			}
+		})
+	}
+
+	if packed, complete := PackDiff("not a diff", 1); packed != "not a diff" || !complete {
+		t.Errorf("PackDiff of a non-diff = %q, %v", packed, complete)
+	}
+}
+
+func TestGroupDiff(t *testing.T) {
+	files := ParseDiff(twoFileDiff)
+	huge := FileDiff{
+		Header: "diff --git a/big.txt b/big.txt\n",
+		Hunks:  []string{"@@ -1 +1 @@\n+" + strings.Repeat("é", 500) + "\n"},
+	}
+
+	tests := []struct {
+		name   string
+		files  []FileDiff
+		budget int
+		groups int
+	}{
+		{name: "one group", files: files, budget: 10000, groups: 1},
+		{name: "one file per group", files: files, budget: 60, groups: 2},
+		{name: "file split between hunks", files: files[:1], budget: 30, groups: 2},
+		{name: "oversized hunk is cut", files: []FileDiff{huge}, budget: 50, groups: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := GroupDiff(tt.files, tt.budget)
+			if len(got) != tt.groups {
+				t.Fatalf("got %d groups, want %d:\n%q", len(got), tt.groups, got)
+			}
+			for i, g := range got {
+				if len(g) > tt.budget*4 {
+					t.Errorf("group %d is %d bytes, over the %d byte limit", i, len(g), tt.budget*4)
+				}
+				if !utf8.ValidString(g) {
+					t.Errorf("group %d splits a character", i)
+				}
+				if !strings.HasPrefix(g, "diff --git ") {
+					t.Errorf("group %d doesn't start with a header: %q", i, g)
+				}
+			}
+		})
+	}
+}
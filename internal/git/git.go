@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 )
 
@@ -32,6 +33,18 @@ func GetRepoRoot() (string, error) {
 	return strings.TrimSpace(out.String()), nil
 }
 
+// GetGitCommonDir returns the repository's shared .git directory, which
+// linked worktrees and submodules keep outside the work tree.
+func GetGitCommonDir() (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--git-common-dir")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+	return filepath.Abs(strings.TrimSpace(out.String()))
+}
+
 func Status() (string, error) {
 	cmd := exec.Command("git", "status")
 	var out bytes.Buffer
@@ -0,0 +1,170 @@
+package prompt
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Tasks with a configurable prompt, as used under `prompts:` in the config.
+const (
+	Commit    = "commit"
+	PR        = "pr"
+	Changelog = "changelog"
+	Resolve   = "resolve"
+	Refactor  = "refactor"
+	Fix       = "fix"
+	Summarize = "summarize"
+	Rewrite   = "rewrite"
+)
+
+var Tasks = []string{Commit, PR, Changelog, Resolve, Refactor, Fix, Summarize, Rewrite}
+
+// DefaultSystem is the system prompt for commit messages when the config
+// sets none.
+const DefaultSystem = "You are an expert developer. Generate a raw git commit message. Output ONLY the message. Structure: a short title, then a blank line, then a description. No conversational filler, no quotes, no backticks."
+
+// Data holds the fields a template can use. Not every field is set for
+// every task: .Commits is the changelog's input, .Error the fix task's,
+// and .Content and .Instruction belong to resolve and refactor. Summarize
+// gets one part of an oversized diff as .Diff; rewrite gets the message as
+// .Content, the commit rules as .Instruction and what breaks them as .Error.
+type Data struct {
+	Diff          string
+	Context       string
+	Branch        string
+	RecentCommits []string
+	Files         []string
+	Stats         string
+	Language      string
+	Style         string
+
+	Commits     string
+	Error       string
+	Content     string
+	Instruction string
+}
+
+var Defaults = map[string]string{
+	Commit: "Generate a raw git commit message for the changes below. Output ONLY the message. " +
+		"Structure: a short title, then a blank line, then a description. No conversational filler, no quotes, no backticks.\n\n" +
+		"Changes:\n{{.Diff}}\n\n{{.Context}}",
+	PR: "Generate a Pull Request title and description for the changes below. " +
+		"Put the title on the first line, then a blank line, then the description.\n\n" +
+		"Changes:\n{{.Diff}}\n\n{{.Context}}",
+	Changelog: "You are a release manager. Group the following commit messages into a beautifully formatted Markdown Changelog. " +
+		"Categorize them into '✨ Features', '🐛 Bug Fixes', and '🛠️ Maintenance' (or similar). " +
+		"Do NOT include markdown codeblocks around your entire response. Here are the commits:\n\n{{.Commits}}",
+	Resolve:  "File Content:\n{{.Content}}",
+	Refactor: "User Prompt: {{.Instruction}}\n\nFile Content:\n{{.Content}}",
+	Fix: "I encountered the following error while working in my repository. " +
+		"Please analyze the error, explain what might be causing it, and provide the exact fix or shell commands to resolve it:\n\n{{.Error}}",
+	Summarize: "Summarize what changed in the diff below so that someone who cannot see it can write the commit message or pull request description. " +
+		"Use short bullet points grouped by file. Mention new or removed functions, behavior changes and notable refactors. " +
+		"Do not speculate beyond the diff and do not write a commit message.\n\n{{.Diff}}",
+	Rewrite: "{{.Instruction}}\n\nThis message breaks them:\n{{.Error}}\n\nMessage:\n{{.Content}}",
+}
+
+var funcs = template.FuncMap{
+	"join": func(items []string, sep string) string { return strings.Join(items, sep) },
+}
+
+// Upgrade converts an old fmt-style commit template, whose two %s stood
+// for the diff and the context, into template syntax. Anything that
+// already uses {{ }} is returned as is.
+func Upgrade(tmpl string) string {
+	if strings.Contains(tmpl, "{{") || !strings.Contains(tmpl, "%s") {
+		return tmpl
+	}
+	tmpl = strings.Replace(tmpl, "%s", "{{.Diff}}", 1)
+	tmpl = strings.Replace(tmpl, "%s", "{{.Context}}", 1)
+	return strings.ReplaceAll(tmpl, "%%", "%")
+}
+
+func Execute(tmpl string, data Data) (string, error) {
+	t, err := template.New("prompt").Funcs(funcs).Parse(Upgrade(tmpl))
+	if err != nil {
+		return "", fmt.Errorf("invalid prompt template: %w", err)
+	}
+	var sb strings.Builder
+	if err := t.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("invalid prompt template: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// Check reports whether tmpl parses and only refers to known fields.
+func Check(tmpl string) error {
+	_, err := Execute(tmpl, Data{RecentCommits: []string{}, Files: []string{}})
+	return err
+}
+
+// Set is the prompt configuration of one run: template overrides keyed by
+// task, and the fields shared by every prompt, such as the branch.
+// A nil Set renders the defaults.
+type Set struct {
+	Templates map[string]string
+	Data      Data
+}
+
+// Template returns the override for task, else fallback, else the default.
+func (s *Set) Template(task string, fallback string) string {
+	if s != nil && s.Templates[task] != "" {
+		return s.Templates[task]
+	}
+	if fallback != "" {
+		return fallback
+	}
+	return Defaults[task]
+}
+
+// Execute renders tmpl with data, filling fields data leaves empty from the set.
+func (s *Set) Execute(tmpl string, data Data) (string, error) {
+	if s != nil {
+		data = merge(data, s.Data)
+	}
+	return Execute(tmpl, data)
+}
+
+func (s *Set) Render(task string, data Data) (string, error) {
+	return s.Execute(s.Template(task, ""), data)
+}
+
+func merge(data Data, base Data) Data {
+	fill := func(dst *string, src string) {
+		if *dst == "" {
+			*dst = src
+		}
+	}
+	fill(&data.Diff, base.Diff)
+	fill(&data.Context, base.Context)
+	fill(&data.Branch, base.Branch)
+	fill(&data.Stats, base.Stats)
+	fill(&data.Language, base.Language)
+	fill(&data.Style, base.Style)
+	fill(&data.Commits, base.Commits)
+	fill(&data.Error, base.Error)
+	fill(&data.Content, base.Content)
+	fill(&data.Instruction, base.Instruction)
+	if data.RecentCommits == nil {
+		data.RecentCommits = base.RecentCommits
+	}
+	if data.Files == nil {
+		data.Files = base.Files
+	}
+	return data
+}
+
+type contextKey struct{}
+
+// With attaches s to ctx, so providers render prompts with the run's settings.
+func With(ctx context.Context, s *Set) context.Context {
+	return context.WithValue(ctx, contextKey{}, s)
+}
+
+// From returns the Set attached to ctx, or nil.
+func From(ctx context.Context) *Set {
+	s, _ := ctx.Value(contextKey{}).(*Set)
+	return s
+}
@@ -0,0 +1,33 @@
+package prompt
+
+import "testing"
+
+func TestUpgrade(t *testing.T) {
+	tests := []struct {
+		name, tmpl, want string
+	}{
+		{"fmt style", "Diff:\n%s\n\nContext:\n%s", "Diff:\n{{.Diff}}\n\nContext:\n{{.Context}}"},
+		{"diff only", "Write a message for %s", "Write a message for {{.Diff}}"},
+		{"escaped percent", "100%% sure: %s", "100% sure: {{.Diff}}"},
+		{"already a template", "{{.Diff}} at 50%s", "{{.Diff}} at 50%s"},
+		{"no verbs", "Just write it", "Just write it"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Upgrade(tt.tmpl); got != tt.want {
+				t.Errorf("Upgrade(%q) = %q, want %q", tt.tmpl, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteUpgradedTemplate(t *testing.T) {
+	got, err := Execute("Changes:\n%s\n%s", Data{Diff: "+a", Context: "ctx"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Changes:\n+a\nctx"; got != want {
+		t.Errorf("Execute = %q, want %q", got, want)
+	}
+}
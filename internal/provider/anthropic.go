@@ -2,93 +2,152 @@ package provider
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+
+	"github.com/eliau2005/ai-git/internal/config"
+	"github.com/eliau2005/ai-git/internal/prompt"
 )
 
 type AnthropicProvider struct {
+	Name         string // config entry name; empty means "anthropic"
 	APIKey       string
 	Model        string
 	SystemPrompt string
 	CommitPrompt string
+	Params       config.GenerationConfig
+	HTTPConfig
 }
 
 func (p *AnthropicProvider) GetName() string {
+	if p.Name != "" {
+		return p.Name
+	}
 	return "anthropic"
 }
 
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
 type anthropicMessagesRequest struct {
-	Model     string `json:"model"`
-	System    string `json:"system,omitempty"`
-	MaxTokens int    `json:"max_tokens"`
-	Messages  []struct {
-		Role    string `json:"role"`
-		Content string `json:"content"`
-	} `json:"messages"`
+	Model      string               `json:"model"`
+	System     string               `json:"system,omitempty"`
+	MaxTokens  int                  `json:"max_tokens"`
+	Stream     bool                 `json:"stream,omitempty"`
+	Messages   []anthropicMessage   `json:"messages"`
+	Tools      []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice *anthropicToolChoice `json:"tool_choice,omitempty"`
+
+	Temperature   *float64 `json:"temperature,omitempty"`
+	TopP          *float64 `json:"top_p,omitempty"`
+	StopSequences []string `json:"stop_sequences,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
 }
 
 type anthropicMessagesResponse struct {
 	Content []struct {
-		Text string `json:"text"`
+		Type  string          `json:"type"`
+		Text  string          `json:"text"`
+		Input json.RawMessage `json:"input"` // tool_use blocks
 	} `json:"content"`
+	Usage anthropicUsage `json:"usage"`
 	Error struct {
 		Type    string `json:"type"`
 		Message string `json:"message"`
 	} `json:"error,omitempty"`
 }
 
-func (p *AnthropicProvider) GenerateCommitMessage(diff string, context string) (string, error) {
-	// Truncate
-	if len(diff) > 15000 {
-		diff = diff[:15000] + "\n... [Diff truncated] ..."
+func (p *AnthropicProvider) GenerateCommitMessage(ctx context.Context, diff string, contextStr string) (string, error) {
+	systemPrompt := p.SystemPrompt
+	if systemPrompt == "" {
+		systemPrompt = prompt.DefaultSystem
+	}
+
+	userPrompt, err := commitPrompt(ctx, p.CommitPrompt, diff, contextStr)
+	if err != nil {
+		return "", err
 	}
 
-	url := "https://api.anthropic.com/v1/messages"
+	return p.Complete(ctx, systemPrompt, []Message{{Role: "user", Content: userPrompt}})
+}
 
-	systemPrompt := p.SystemPrompt
-	if systemPrompt == "" {
-		systemPrompt = "You are an expert developer. Generate a raw git commit message. Output ONLY the message. Structure: a short title, then a blank line, then a description. No conversational filler, no quotes, no backticks."
+func (p *AnthropicProvider) Complete(ctx context.Context, system string, messages []Message) (string, error) {
+	result, err := p.send(ctx, p.newRequest(system, messages))
+	if err != nil {
+		return "", err
 	}
 
-	commitPromptTemplate := p.CommitPrompt
-	if commitPromptTemplate == "" {
-		commitPromptTemplate = "Generate a git commit message for these changes:\n\n%s\n\n%s"
+	for _, block := range result.Content {
+		if block.Type == "text" || block.Type == "" {
+			return block.Text, nil
+		}
 	}
 
-	userPrompt := fmt.Sprintf(commitPromptTemplate, diff, context)
-
-	reqBody := anthropicMessagesRequest{
-		Model:     p.Model,
-		System:    systemPrompt,
-		MaxTokens: 1024,
-		Messages: []struct {
-			Role    string `json:"role"`
-			Content string `json:"content"`
-		}{
-			{Role: "user", Content: userPrompt},
-		},
+	return "", fmt.Errorf("no response from Anthropic")
+}
+
+// CompleteJSON forces a call to a single tool whose input schema is the
+// requested one; Anthropic has no JSON mode, but tool inputs are structured.
+func (p *AnthropicProvider) CompleteJSON(ctx context.Context, system string, messages []Message, schema map[string]any) (string, error) {
+	const tool = "respond"
+	reqBody := p.newRequest(system, messages)
+	reqBody.Tools = []anthropicTool{{Name: tool, Description: "Submit the response.", InputSchema: schema}}
+	reqBody.ToolChoice = &anthropicToolChoice{Type: "tool", Name: tool}
+	result, err := p.send(ctx, reqBody)
+	if err != nil {
+		return "", err
 	}
 
+	for _, block := range result.Content {
+		if block.Type == "tool_use" {
+			return string(block.Input), nil
+		}
+	}
+
+	return "", fmt.Errorf("no structured response from Anthropic")
+}
+
+func (p *AnthropicProvider) send(ctx context.Context, reqBody anthropicMessagesRequest) (*anthropicMessagesResponse, error) {
+	url := "https://api.anthropic.com/v1/messages"
+
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("x-api-key", p.APIKey)
 	req.Header.Set("anthropic-version", "2023-06-01")
 
-	client := &http.Client{}
+	client := p.httpClient()
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
@@ -96,19 +155,25 @@ func (p *AnthropicProvider) GenerateCommitMessage(diff string, context string) (
 		var errResp anthropicMessagesResponse
 		body, _ := io.ReadAll(resp.Body)
 		if json.Unmarshal(body, &errResp) == nil && errResp.Error.Message != "" {
-			return "", fmt.Errorf("Anthropic API error: %s (Type: %s)", errResp.Error.Message, errResp.Error.Type)
+			return nil, apiError(resp, fmt.Errorf("Anthropic API error: %s (Type: %s)", errResp.Error.Message, errResp.Error.Type))
 		}
-		return "", fmt.Errorf("Anthropic API error: %s", string(body))
+		return nil, apiError(resp, fmt.Errorf("Anthropic API error: %s", string(body)))
 	}
 
 	var result anthropicMessagesResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
+		return nil, err
 	}
 
-	if len(result.Content) > 0 {
-		return result.Content[0].Text, nil
-	}
+	p.reportUsage("anthropic", p.GetName(), p.Model, result.Usage.InputTokens, result.Usage.OutputTokens)
 
-	return "", fmt.Errorf("no response from Anthropic")
+	return &result, nil
+}
+
+func anthropicMessages(messages []Message) []anthropicMessage {
+	out := make([]anthropicMessage, 0, len(messages))
+	for _, m := range messages {
+		out = append(out, anthropicMessage{Role: m.Role, Content: m.Content})
+	}
+	return out
 }
@@ -0,0 +1,102 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// anthropicStreamEvent covers the SSE payloads we care about:
+// content_block_delta carries text, error carries a mid-stream failure,
+// message_start and message_delta carry input and output token counts.
+type anthropicStreamEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Message struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	Usage anthropicUsage `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *AnthropicProvider) AskChatStream(ctx context.Context, messages []Message, contextStr string, onChunk func(string)) error {
+	url := "https://api.anthropic.com/v1/messages"
+
+	reqBody := p.newRequest(p.SystemPrompt, chatMessages(messages, contextStr))
+	reqBody.Stream = true
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", p.APIKey)
+	req.Header.Set("anthropic-version", "2023-06-01")
+	req.Header.Set("Accept", "text/event-stream")
+
+	client := p.streamingClient()
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp anthropicMessagesResponse
+		body, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(body, &errResp) == nil && errResp.Error.Message != "" {
+			return apiError(resp, fmt.Errorf("Anthropic API error: %s (Type: %s)", errResp.Error.Message, errResp.Error.Type))
+		}
+		return apiError(resp, fmt.Errorf("Anthropic API error: %s", string(body)))
+	}
+
+	var usage anthropicUsage
+	defer func() {
+		p.reportUsage("anthropic", p.GetName(), p.Model, usage.InputTokens, usage.OutputTokens)
+	}()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+			continue
+		}
+		switch event.Type {
+		case "message_start":
+			usage.InputTokens = event.Message.Usage.InputTokens
+		case "message_delta":
+			usage.OutputTokens = event.Usage.OutputTokens
+		case "content_block_delta":
+			if event.Delta.Text != "" {
+				onChunk(event.Delta.Text)
+			}
+		case "error":
+			return fmt.Errorf("Anthropic API error: %s (Type: %s)", event.Error.Message, event.Error.Type)
+		case "message_stop":
+			return nil
+		}
+	}
+
+	return scanner.Err()
+}
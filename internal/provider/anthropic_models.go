@@ -0,0 +1,29 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+)
+
+func (p *AnthropicProvider) ListModels(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequest("GET", "https://api.anthropic.com/v1/models?limit=1000", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-api-key", p.APIKey)
+	req.Header.Set("anthropic-version", "2023-06-01")
+
+	var result struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := getJSON(ctx, p.httpClient(), req, "Anthropic", &result); err != nil {
+		return nil, err
+	}
+	models := make([]string, 0, len(result.Data))
+	for _, m := range result.Data {
+		models = append(models, m.ID)
+	}
+	return models, nil
+}
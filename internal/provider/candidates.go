@@ -0,0 +1,64 @@
+package provider
+
+import (
+	"context"
+	"sync"
+)
+
+// MultiGenerator is implemented by providers that can return several
+// alternative commit messages from a single request.
+type MultiGenerator interface {
+	GenerateCommitMessages(ctx context.Context, diff string, contextStr string, n int) ([]string, error)
+}
+
+// GenerateCandidates returns up to n alternative commit messages. It uses
+// the provider's native multi-choice support where available and tops up
+// with parallel single requests otherwise (some OpenAI-compatible servers
+// silently ignore `n`). An error is returned only if nothing was produced.
+func GenerateCandidates(ctx context.Context, p Provider, diff string, contextStr string, n int) ([]string, error) {
+	if n <= 1 {
+		msg, err := p.GenerateCommitMessage(ctx, diff, contextStr)
+		if err != nil {
+			return nil, err
+		}
+		return []string{msg}, nil
+	}
+
+	var candidates []string
+	var firstErr error
+	if mg, ok := p.(MultiGenerator); ok {
+		candidates, firstErr = mg.GenerateCommitMessages(ctx, diff, contextStr, n)
+		if len(candidates) > n {
+			candidates = candidates[:n]
+		}
+	}
+
+	missing := n - len(candidates)
+	if missing > 0 {
+		results := make([]string, missing)
+		errs := make([]error, missing)
+		var wg sync.WaitGroup
+		for i := 0; i < missing; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i], errs[i] = p.GenerateCommitMessage(ctx, diff, contextStr)
+			}(i)
+		}
+		wg.Wait()
+		for i := range results {
+			if errs[i] != nil {
+				if firstErr == nil {
+					firstErr = errs[i]
+				}
+				continue
+			}
+			candidates = append(candidates, results[i])
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, firstErr
+	}
+	return candidates, nil
+}
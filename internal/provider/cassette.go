@@ -0,0 +1,269 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// RecordEnv names the environment variable that, when set to a file path,
+// records every provider HTTP exchange into that cassette.
+const RecordEnv = "AI_GIT_RECORD"
+
+const redacted = "REDACTED"
+
+// Cassette is a recorded series of HTTP exchanges with one or more providers.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+type Interaction struct {
+	Provider string           `json:"provider"` // implementation kind, e.g. "openai"
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body"`
+}
+
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid cassette %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// Kind returns the provider implementation the cassette was recorded with.
+func (c *Cassette) Kind() string {
+	if len(c.Interactions) == 0 {
+		return ""
+	}
+	return c.Interactions[0].Provider
+}
+
+// recorders are shared per cassette path, so every provider built in one
+// run appends to the same file.
+var (
+	recordersMu sync.Mutex
+	recorders   = map[string]*recorder{}
+)
+
+type recorder struct {
+	mu       sync.Mutex
+	path     string
+	cassette Cassette
+}
+
+// recordingTransport passes requests through to base and appends each
+// exchange, with credentials redacted, to the cassette at path. Streamed
+// responses are buffered in full before being handed on.
+type recordingTransport struct {
+	base     http.RoundTripper
+	kind     string
+	secrets  []string
+	recorder *recorder
+}
+
+func newRecordingTransport(base http.RoundTripper, path string, kind string, secrets ...string) *recordingTransport {
+	recordersMu.Lock()
+	defer recordersMu.Unlock()
+	r, ok := recorders[path]
+	if !ok {
+		r = &recorder{path: path}
+		// Keep what an earlier run recorded into the same file
+		if c, err := LoadCassette(path); err == nil {
+			r.cassette = *c
+		}
+		recorders[path] = r
+	}
+	return &recordingTransport{base: base, kind: kind, secrets: secrets, recorder: r}
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	interaction := Interaction{
+		Provider: t.kind,
+		Request: RecordedRequest{
+			Method:  req.Method,
+			URL:     t.redact(redactURL(req.URL)),
+			Headers: t.redactHeaders(req.Header),
+			Body:    t.redact(string(reqBody)),
+		},
+		Response: RecordedResponse{
+			Status:  resp.StatusCode,
+			Headers: t.redactHeaders(resp.Header),
+			Body:    t.redact(string(respBody)),
+		},
+	}
+
+	r := t.recorder
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	saveErr := r.cassette.Save(r.path)
+	r.mu.Unlock()
+	if saveErr != nil {
+		return nil, fmt.Errorf("failed to write cassette: %w", saveErr)
+	}
+	return resp, nil
+}
+
+// sensitiveHeaders carry credentials for the providers we support.
+var sensitiveHeaders = map[string]bool{
+	"authorization":  true,
+	"x-api-key":      true,
+	"api-key":        true,
+	"x-goog-api-key": true,
+	"cookie":         true,
+	"set-cookie":     true,
+}
+
+func (t *recordingTransport) redactHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for k, v := range h {
+		value := strings.Join(v, ", ")
+		if sensitiveHeaders[strings.ToLower(k)] {
+			value = redacted
+		}
+		out[k] = t.redact(value)
+	}
+	return out
+}
+
+// redact blanks out any configured secret wherever it appears. Very short
+// values are skipped, as replacing them would mangle unrelated text.
+func (t *recordingTransport) redact(s string) string {
+	for _, secret := range t.secrets {
+		if len(secret) >= 8 {
+			s = strings.ReplaceAll(s, secret, redacted)
+		}
+	}
+	return s
+}
+
+// redactURL hides the `key` query parameter Gemini authenticates with.
+func redactURL(u *url.URL) string {
+	q := u.Query()
+	if q.Has("key") {
+		q.Set("key", redacted)
+	}
+	clean := *u
+	clean.RawQuery = q.Encode()
+	return clean.String()
+}
+
+// replayTransport answers requests from a cassette without touching the
+// network. An exchange whose URL and body match exactly is preferred;
+// otherwise the next unused one for the same method and URL is served,
+// so replays survive prompt tweaks.
+type replayTransport struct {
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+func newReplayTransport(c *Cassette) *replayTransport {
+	return &replayTransport{cassette: c, used: make([]bool, len(c.Interactions))}
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+	reqURL := redactURL(req.URL)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	match := -1
+	for i, in := range t.cassette.Interactions {
+		if t.used[i] || in.Request.Method != req.Method || in.Request.URL != reqURL {
+			continue
+		}
+		if in.Request.Body == string(body) {
+			match = i
+			break
+		}
+		if match == -1 {
+			match = i
+		}
+	}
+	if match == -1 {
+		return nil, fmt.Errorf("replay: no recorded response left for %s %s", req.Method, reqURL)
+	}
+	t.used[match] = true
+
+	recorded := t.cassette.Interactions[match].Response
+	resp := &http.Response{
+		StatusCode:    recorded.Status,
+		Status:        fmt.Sprintf("%d %s", recorded.Status, http.StatusText(recorded.Status)),
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		Body:          io.NopCloser(strings.NewReader(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}
+	for k, v := range recorded.Headers {
+		resp.Header.Set(k, v)
+	}
+	return resp, nil
+}
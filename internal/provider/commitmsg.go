@@ -0,0 +1,293 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/eliau2005/ai-git/internal/prompt"
+)
+
+// CommitMessage is a commit message broken into its conventional-commit parts.
+type CommitMessage struct {
+	Type     string   `json:"type"`
+	Scope    string   `json:"scope"`
+	Subject  string   `json:"subject"`
+	Body     string   `json:"body"`
+	Breaking bool     `json:"breaking"`
+	Footers  []string `json:"footers"`
+}
+
+// JSONCompleter is implemented by providers with a native JSON or
+// structured-output mode; the reply is constrained to schema.
+type JSONCompleter interface {
+	CompleteJSON(ctx context.Context, system string, messages []Message, schema map[string]any) (string, error)
+}
+
+// commitMessageSchema is the JSON schema of CommitMessage under r. Every
+// property is required and no others are allowed, as strict
+// structured-output modes demand.
+func (r CommitRules) commitMessageSchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"type":     map[string]any{"type": "string", "description": orList(r.types())},
+			"scope":    map[string]any{"type": "string", "description": "area of the codebase, or empty"},
+			"subject":  map[string]any{"type": "string", "description": fmt.Sprintf("imperative summary, at most %d characters, no trailing period", r.maxSubject())},
+			"body":     map[string]any{"type": "string", "description": "what changed and why, or empty"},
+			"breaking": map[string]any{"type": "boolean"},
+			"footers":  map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "e.g. \"Refs: #123\", or empty"},
+		},
+		"required":             []string{"type", "scope", "subject", "body", "breaking", "footers"},
+		"additionalProperties": false,
+	}
+}
+
+// structuredSystemPrompt asks for a CommitMessage as JSON under r.
+func (r CommitRules) structuredSystemPrompt() string {
+	return "You are an expert developer writing a git commit message. " +
+		"Reply with a single JSON object with the fields type, scope, subject, body, breaking and footers. " +
+		fmt.Sprintf("type is one of %s. ", orList(r.types())) +
+		fmt.Sprintf("subject is an imperative summary of at most %d characters without a trailing period. ", r.maxSubject()) +
+		"Use an empty string for scope or body and an empty list for footers when they don't apply. " +
+		"Output only the JSON, with no markdown."
+}
+
+// orList joins items as "a, b or c".
+func orList(items []string) string {
+	if len(items) < 2 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " or " + items[len(items)-1]
+}
+
+// commitPrompt renders the user prompt for a commit message. The run's
+// template from ctx wins over tmpl, the provider's configured one.
+func commitPrompt(ctx context.Context, tmpl string, diff string, contextStr string) (string, error) {
+	set := prompt.From(ctx)
+	return set.Execute(set.Template(prompt.Commit, tmpl), prompt.Data{Diff: diff, Context: contextStr})
+}
+
+// GenerateStructured asks p for a CommitMessage, using its JSON mode where
+// available and falling back to tolerant parsing of a free-text answer.
+// The user prompt is the run's commit template; system, if set, comes
+// before the JSON instructions, which follow rules' types and subject length.
+func GenerateStructured(ctx context.Context, p Provider, system string, rules CommitRules, diff string, contextStr string) (CommitMessage, error) {
+	userPrompt, err := commitPrompt(ctx, "", diff, contextStr)
+	if err != nil {
+		return CommitMessage{}, err
+	}
+	messages := []Message{{Role: "user", Content: userPrompt}}
+	if system != "" {
+		system += "\n\n"
+	}
+	system += rules.structuredSystemPrompt()
+
+	var text string
+	switch c := p.(type) {
+	case JSONCompleter:
+		text, err = c.CompleteJSON(ctx, system, messages, rules.commitMessageSchema())
+	case Completer:
+		text, err = c.Complete(ctx, system, messages)
+	default:
+		text, err = p.GenerateCommitMessage(ctx, diff, contextStr)
+	}
+	if err != nil {
+		return CommitMessage{}, err
+	}
+	return ParseCommitMessage(text)
+}
+
+var (
+	conventionalHeader = regexp.MustCompile(`^(\w+)(?:\(([^)]*)\))?(!)?:\s*(.+)$`)
+	footerLine         = regexp.MustCompile(`^(BREAKING[ -]CHANGE|[\w-]+)(?::\s| #)`)
+	preambleLine       = regexp.MustCompile(`(?i)^(here('s| is)|sure|certainly|commit message)\b.*:?$`)
+)
+
+// ParseCommitMessage reads a model's answer as JSON if it contains an
+// object, otherwise as a free-text message, skipping chatty preambles,
+// quotes and code fences.
+func ParseCommitMessage(text string) (CommitMessage, error) {
+	text = strings.TrimSpace(stripCodeFence(text))
+
+	if start, end := strings.Index(text, "{"), strings.LastIndex(text, "}"); start != -1 && end > start {
+		var msg CommitMessage
+		if err := json.Unmarshal([]byte(text[start:end+1]), &msg); err == nil && msg.Subject != "" {
+			msg.normalize()
+			return msg, nil
+		}
+	}
+
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		lines = append(lines, strings.TrimRight(line, " \t"))
+	}
+	// Drop leading blank and "Here is your commit message:" lines
+	for len(lines) > 0 && (strings.TrimSpace(lines[0]) == "" || preambleLine.MatchString(strings.TrimSpace(lines[0]))) {
+		lines = lines[1:]
+	}
+	if len(lines) == 0 {
+		return CommitMessage{}, fmt.Errorf("empty commit message")
+	}
+
+	var msg CommitMessage
+	header := strings.Trim(strings.TrimSpace(lines[0]), "\"'`*")
+	header = strings.TrimPrefix(header, "Title: ")
+	if m := conventionalHeader.FindStringSubmatch(header); m != nil {
+		msg.Type, msg.Scope, msg.Breaking, msg.Subject = m[1], m[2], m[3] == "!", m[4]
+	} else {
+		msg.Subject = header
+	}
+
+	// Trailing lines that look like git trailers are footers
+	rest := lines[1:]
+	end := len(rest)
+	for end > 0 && strings.TrimSpace(rest[end-1]) == "" {
+		end--
+	}
+	start := end
+	for start > 0 && footerLine.MatchString(rest[start-1]) {
+		start--
+	}
+	if start > 0 && strings.TrimSpace(rest[start-1]) != "" {
+		// Trailers must be a separate paragraph
+		start = end
+	}
+	for _, f := range rest[start:end] {
+		if strings.HasPrefix(f, "BREAKING") {
+			msg.Breaking = true
+		}
+		msg.Footers = append(msg.Footers, f)
+	}
+	body := strings.Join(rest[:start], "\n")
+	body = strings.TrimPrefix(strings.TrimSpace(body), "Description:")
+	msg.Body = strings.Trim(strings.TrimSpace(body), "\"")
+
+	msg.normalize()
+	return msg, nil
+}
+
+func (m *CommitMessage) normalize() {
+	m.Type = strings.ToLower(strings.TrimSpace(m.Type))
+	m.Scope = strings.TrimSpace(m.Scope)
+	m.Subject = strings.TrimSuffix(strings.TrimSpace(m.Subject), ".")
+	m.Body = strings.TrimSpace(m.Body)
+	var footers []string
+	for _, f := range m.Footers {
+		if f = strings.TrimSpace(f); f != "" {
+			footers = append(footers, f)
+		}
+	}
+	m.Footers = footers
+}
+
+var gitmojis = map[string]string{
+	"feat":     "✨",
+	"fix":      "🐛",
+	"docs":     "📝",
+	"style":    "🎨",
+	"refactor": "♻️",
+	"perf":     "⚡️",
+	"test":     "✅",
+	"build":    "👷",
+	"ci":       "💚",
+	"chore":    "🔧",
+	"revert":   "⏪️",
+}
+
+// Render formats the message in one of the CommitStyles, conventional by
+// default. The short style is the title alone.
+func (m CommitMessage) Render(style string) string {
+	var title string
+	switch style {
+	case "gitmoji":
+		emoji, ok := gitmojis[m.Type]
+		if !ok {
+			emoji = "🔧"
+		}
+		if m.Breaking {
+			emoji = "💥"
+		}
+		title = emoji + " " + capitalize(m.Subject)
+	case "short":
+		return capitalize(m.Subject)
+	case "plain", "detailed":
+		title = capitalize(m.Subject)
+	case "conventional", "":
+		typ := m.Type
+		if typ == "" {
+			typ = "chore"
+		}
+		title = typ
+		if m.Scope != "" {
+			title += "(" + m.Scope + ")"
+		}
+		if m.Breaking {
+			title += "!"
+		}
+		title += ": " + m.Subject
+	default:
+		return m.Render("conventional")
+	}
+
+	parts := []string{title}
+	if m.Body != "" {
+		parts = append(parts, m.Body)
+	}
+	footers := m.Footers
+	if m.Breaking && style != "conventional" && style != "" && !hasBreakingFooter(footers) {
+		// Styles without "!" need the footer to carry the flag
+		footers = append([]string{"BREAKING CHANGE: " + m.Subject}, footers...)
+	}
+	if len(footers) > 0 {
+		parts = append(parts, strings.Join(footers, "\n"))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func hasBreakingFooter(footers []string) bool {
+	for _, f := range footers {
+		if strings.HasPrefix(f, "BREAKING") {
+			return true
+		}
+	}
+	return false
+}
+
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+// StructuredProvider generates commit messages as a CommitMessage and
+// renders them with Render, so free-text quirks like preambles and quotes
+// never reach the title. Other capabilities pass through to Provider.
+type StructuredProvider struct {
+	Provider
+	SystemPrompt string
+	Rules        CommitRules
+	Render       func(CommitMessage) string
+}
+
+func (s *StructuredProvider) GenerateCommitMessage(ctx context.Context, diff string, contextStr string) (string, error) {
+	msg, err := GenerateStructured(ctx, s.Provider, s.SystemPrompt, s.Rules, diff, contextStr)
+	if err != nil {
+		return "", err
+	}
+	return s.Render(msg), nil
+}
+
+func (s *StructuredProvider) Complete(ctx context.Context, system string, messages []Message) (string, error) {
+	c, ok := s.Provider.(Completer)
+	if !ok {
+		return "", fmt.Errorf("provider %s does not support completions", s.GetName())
+	}
+	return c.Complete(ctx, system, messages)
+}
@@ -0,0 +1,130 @@
+package provider
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCommitMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    CommitMessage
+		wantErr bool
+	}{
+		{
+			name: "conventional with body",
+			text: "feat(api): add retries.\n\nRetry rate limited requests.",
+			want: CommitMessage{Type: "feat", Scope: "api", Subject: "add retries", Body: "Retry rate limited requests."},
+		},
+		{
+			name: "json",
+			text: `{"type":"Fix","scope":"","subject":"handle nil config","body":"","breaking":false,"footers":["Refs: #12"]}`,
+			want: CommitMessage{Type: "fix", Subject: "handle nil config", Footers: []string{"Refs: #12"}},
+		},
+		{
+			name: "json in a code fence",
+			text: "```json\n{\"type\":\"docs\",\"subject\":\"update readme\"}\n```",
+			want: CommitMessage{Type: "docs", Subject: "update readme"},
+		},
+		{
+			name: "preamble and quotes",
+			text: "Here is your commit message:\n\n\"fix: stop leaking file handles\"",
+			want: CommitMessage{Type: "fix", Subject: "stop leaking file handles"},
+		},
+		{
+			name: "breaking with footers",
+			text: "refactor!: drop the v1 API\n\nClients must move to v2.\n\nBREAKING CHANGE: v1 is gone\nRefs: #7",
+			want: CommitMessage{
+				Type: "refactor", Subject: "drop the v1 API", Body: "Clients must move to v2.", Breaking: true,
+				Footers: []string{"BREAKING CHANGE: v1 is gone", "Refs: #7"},
+			},
+		},
+		{
+			name: "trailer-like line inside the body",
+			text: "Update docs\n\nNote: this is not a trailer\nbecause it's part of the paragraph",
+			want: CommitMessage{Subject: "Update docs", Body: "Note: this is not a trailer\nbecause it's part of the paragraph"},
+		},
+		{
+			name:    "empty",
+			text:    "  \n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCommitMessage(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommitMessageRender(t *testing.T) {
+	msg := CommitMessage{Type: "feat", Scope: "cli", Subject: "add a flag", Body: "Details.", Breaking: true}
+	tests := []struct {
+		style string
+		want  string
+	}{
+		{"conventional", "feat(cli)!: add a flag\n\nDetails."},
+		{"", "feat(cli)!: add a flag\n\nDetails."},
+		{"unknown", "feat(cli)!: add a flag\n\nDetails."},
+		{"gitmoji", "💥 Add a flag\n\nDetails.\n\nBREAKING CHANGE: add a flag"},
+		{"plain", "Add a flag\n\nDetails.\n\nBREAKING CHANGE: add a flag"},
+		{"short", "Add a flag"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			if got := msg.Render(tt.style); got != tt.want {
+				t.Errorf("Render(%q) = %q, want %q", tt.style, got, tt.want)
+			}
+		})
+	}
+}
+
+// jsonRecorder answers CompleteJSON with reply and keeps what it was asked.
+type jsonRecorder struct {
+	FakeProvider
+	reply  string
+	system string
+	schema map[string]any
+}
+
+func (r *jsonRecorder) CompleteJSON(ctx context.Context, system string, messages []Message, schema map[string]any) (string, error) {
+	r.system, r.schema = system, schema
+	return r.reply, nil
+}
+
+func TestGenerateStructuredRules(t *testing.T) {
+	rec := &jsonRecorder{reply: `{"type":"hotfix","scope":"","subject":"stop the crash","body":"","breaking":false,"footers":[]}`}
+	rules := CommitRules{Types: []string{"hotfix", "feature"}, MaxSubject: 50}
+	msg, err := GenerateStructured(context.Background(), rec, "Be brief.", rules, "diff", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != "hotfix" || msg.Subject != "stop the crash" {
+		t.Errorf("message = %+v", msg)
+	}
+
+	for _, want := range []string{"Be brief.\n\n", "type is one of hotfix or feature.", "at most 50 characters"} {
+		if !strings.Contains(rec.system, want) {
+			t.Errorf("system prompt lacks %q:\n%s", want, rec.system)
+		}
+	}
+	if strings.Contains(rec.system, "72") || strings.Contains(rec.system, "chore") {
+		t.Errorf("system prompt keeps the default rules:\n%s", rec.system)
+	}
+	props := rec.schema["properties"].(map[string]any)
+	if got := props["type"].(map[string]any)["description"]; got != "hotfix or feature" {
+		t.Errorf("type description = %q", got)
+	}
+	if got := props["subject"].(map[string]any)["description"].(string); !strings.Contains(got, "at most 50 characters") {
+		t.Errorf("subject description = %q", got)
+	}
+}
@@ -0,0 +1,227 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/eliau2005/ai-git/internal/prompt"
+)
+
+// CommitStyles are the values commit_style accepts.
+var CommitStyles = []string{"conventional", "gitmoji", "short", "detailed", "plain"}
+
+// DefaultCommitTypes are the prefixes the conventional style allows unless
+// configured otherwise.
+var DefaultCommitTypes = []string{"feat", "fix", "docs", "style", "refactor", "perf", "test", "build", "ci", "chore", "revert"}
+
+const (
+	defaultMaxSubject      = 72
+	defaultShortMaxSubject = 50
+)
+
+const rewriteSystemPrompt = "You fix git commit messages so they follow the repository's rules. " +
+	"Keep the meaning, change only what the rules require, and output ONLY the corrected message, with no quotes or markdown."
+
+// CommitRules describe the messages a repository accepts. Empty fields
+// take the defaults: conventional style, the style's subject length and
+// DefaultCommitTypes.
+type CommitRules struct {
+	Style      string
+	Language   string
+	MaxSubject int
+	Types      []string // allowed prefixes of the conventional style
+}
+
+func (r CommitRules) style() string {
+	if r.Style == "" {
+		return "conventional"
+	}
+	return r.Style
+}
+
+func (r CommitRules) maxSubject() int {
+	if r.MaxSubject > 0 {
+		return r.MaxSubject
+	}
+	if r.style() == "short" {
+		return defaultShortMaxSubject
+	}
+	return defaultMaxSubject
+}
+
+func (r CommitRules) types() []string {
+	if len(r.Types) > 0 {
+		return r.Types
+	}
+	return DefaultCommitTypes
+}
+
+// Instructions tells the model about the rules; it's appended to the
+// prompt's context.
+func (r CommitRules) Instructions() string {
+	var rules []string
+	switch r.style() {
+	case "gitmoji":
+		rules = append(rules, "Start the title with the gitmoji that fits the change (✨ feature, 🐛 fix, 📝 docs, ♻️ refactor, ⚡️ performance, ✅ tests, 🔧 configuration, 💥 breaking change), then a space and the summary. No type prefix.")
+	case "short":
+		rules = append(rules, "Write a single line and nothing else: no description.")
+	case "detailed":
+		rules = append(rules, "Write a title, a blank line, then a detailed description of what changed and why, as bullet points. No type prefix.")
+	case "plain":
+		rules = append(rules, "Write a plain title without type prefix or emoji, a blank line, then a description.")
+	default:
+		rules = append(rules, fmt.Sprintf("Use Conventional Commits: the title is `type(scope): summary`, with the scope optional and type one of %s.", strings.Join(r.types(), ", ")))
+	}
+	rules = append(rules, fmt.Sprintf("The title is at most %d characters long.", r.maxSubject()))
+	if r.Language != "" {
+		rules = append(rules, fmt.Sprintf("Write the message in %s.", r.Language))
+	}
+	return "Commit message rules:\n- " + strings.Join(rules, "\n- ")
+}
+
+// Check lists the ways msg breaks the rules.
+func (r CommitRules) Check(msg string) []string {
+	title, body := splitTitle(msg)
+	if title == "" {
+		return []string{"the message is empty"}
+	}
+
+	var problems []string
+	if n := utf8.RuneCountInString(title); n > r.maxSubject() {
+		problems = append(problems, fmt.Sprintf("the title is %d characters long, the limit is %d", n, r.maxSubject()))
+	}
+	header := conventionalHeader.FindStringSubmatch(title)
+	switch r.style() {
+	case "gitmoji":
+		if first, _ := utf8.DecodeRuneInString(title); !isEmoji(first) {
+			problems = append(problems, "the title doesn't start with a gitmoji")
+		}
+	case "short":
+		if body != "" {
+			problems = append(problems, "the short style has no description, only a title")
+		}
+	case "detailed":
+		if body == "" {
+			problems = append(problems, "the detailed style needs a description after a blank line")
+		}
+	case "conventional":
+		if header == nil {
+			problems = append(problems, "the title doesn't start with a prefix like `feat: ` or `fix(scope): `")
+		} else if !slices.Contains(r.types(), strings.ToLower(header[1])) {
+			problems = append(problems, fmt.Sprintf("type `%s` is not one of %s", header[1], strings.Join(r.types(), ", ")))
+		}
+	}
+	if r.style() != "conventional" && header != nil && slices.Contains(DefaultCommitTypes, strings.ToLower(header[1])) {
+		problems = append(problems, fmt.Sprintf("the %s style has no `%s:` prefix", r.style(), header[1]))
+	}
+	return problems
+}
+
+// Repair rewrites msg to follow the rules without asking a model: it
+// reformats the title for the style, replaces a disallowed type and
+// shortens the title at a word boundary. A missing description can't be
+// made up, so a detailed message may still lack one.
+func (r CommitRules) Repair(msg string) string {
+	m, err := ParseCommitMessage(msg)
+	if err != nil {
+		return msg
+	}
+	if first, size := utf8.DecodeRuneInString(m.Subject); isEmoji(first) {
+		// A gitmoji title parses as a subject that starts with the emoji
+		m.Subject = strings.TrimSpace(strings.TrimLeftFunc(m.Subject[size:], func(r rune) bool { return r == '\uFE0F' }))
+		if m.Type == "" {
+			m.Type = gitmojiType(first)
+		}
+	}
+	if !slices.Contains(r.types(), m.Type) {
+		m.Type = "chore"
+		if !slices.Contains(r.types(), "chore") {
+			m.Type = r.types()[0]
+		}
+	}
+
+	style := r.style()
+	if style == "conventional" {
+		m.Subject = lowerFirst(m.Subject)
+	}
+	out := m.Render(style)
+	title, _ := splitTitle(out)
+	if over := utf8.RuneCountInString(title) - r.maxSubject(); over > 0 {
+		m.Subject = truncateWords(m.Subject, utf8.RuneCountInString(m.Subject)-over)
+		out = m.Render(style)
+	}
+	return out
+}
+
+// EnforceRules returns msg if it follows r. Otherwise it asks p to fix the
+// message, and if that fails or still breaks a rule, repairs it with Repair.
+func EnforceRules(ctx context.Context, p Provider, r CommitRules, msg string) string {
+	problems := r.Check(msg)
+	if len(problems) == 0 {
+		return msg
+	}
+	if c, ok := p.(Completer); ok {
+		request, err := prompt.From(ctx).Render(prompt.Rewrite, prompt.Data{
+			Instruction: r.Instructions(),
+			Error:       "- " + strings.Join(problems, "\n- "),
+			Content:     msg,
+		})
+		if err != nil {
+			return r.Repair(msg)
+		}
+		fixed, err := c.Complete(ctx, rewriteSystemPrompt, []Message{{Role: "user", Content: request}})
+		if fixed = strings.TrimSpace(stripCodeFence(fixed)); err == nil && fixed != "" {
+			if len(r.Check(fixed)) == 0 {
+				return fixed
+			}
+			msg = fixed
+		}
+	}
+	return r.Repair(msg)
+}
+
+func splitTitle(msg string) (string, string) {
+	title, body, _ := strings.Cut(strings.TrimSpace(msg), "\n")
+	return strings.TrimSpace(title), strings.TrimSpace(body)
+}
+
+func isEmoji(r rune) bool {
+	return unicode.Is(unicode.So, r)
+}
+
+func gitmojiType(emoji rune) string {
+	for typ, e := range gitmojis {
+		if first, _ := utf8.DecodeRuneInString(e); first == emoji {
+			return typ
+		}
+	}
+	return ""
+}
+
+// lowerFirst lowercases the first letter of s unless it starts an
+// acronym like "API".
+func lowerFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	next, _ := utf8.DecodeRuneInString(s[size:])
+	if !unicode.IsUpper(r) || unicode.IsUpper(next) {
+		return s
+	}
+	return string(unicode.ToLower(r)) + s[size:]
+}
+
+// truncateWords cuts s to at most limit runes, preferring a word boundary.
+func truncateWords(s string, limit int) string {
+	runes := []rune(s)
+	if limit <= 0 || len(runes) <= limit {
+		return s
+	}
+	cut := string(runes[:limit])
+	if i := strings.LastIndex(cut, " "); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,;:-")
+}
@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCommitRulesCheck(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules CommitRules
+		msg   string
+		want  []string // substrings of the problems, in order
+	}{
+		{name: "valid conventional", msg: "fix(git): parse renames\n\nDetails."},
+		{name: "empty", msg: "", want: []string{"empty"}},
+		{name: "missing prefix", msg: "Parse renames", want: []string{"doesn't start with a prefix"}},
+		{name: "disallowed type", rules: CommitRules{Types: []string{"feat", "fix"}}, msg: "docs: explain", want: []string{"type `docs` is not one of feat, fix"}},
+		{name: "title too long", rules: CommitRules{MaxSubject: 10}, msg: "fix: a rather long title", want: []string{"the limit is 10"}},
+		{name: "short style with body", rules: CommitRules{Style: "short"}, msg: "Fix it\n\nBecause.", want: []string{"no description"}},
+		{name: "short style default limit", rules: CommitRules{Style: "short"}, msg: strings.Repeat("a", 51), want: []string{"the limit is 50"}},
+		{name: "detailed without body", rules: CommitRules{Style: "detailed"}, msg: "Fix it", want: []string{"needs a description"}},
+		{name: "gitmoji without emoji", rules: CommitRules{Style: "gitmoji"}, msg: "Fix it", want: []string{"doesn't start with a gitmoji"}},
+		{name: "valid gitmoji", rules: CommitRules{Style: "gitmoji"}, msg: "🐛 Fix it"},
+		{name: "plain with prefix", rules: CommitRules{Style: "plain"}, msg: "fix: it\n\nBody.", want: []string{"the plain style has no `fix:` prefix"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.rules.Check(tt.msg)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Check(%q) = %q, want %d problems", tt.msg, got, len(tt.want))
+			}
+			for i, w := range tt.want {
+				if !strings.Contains(got[i], w) {
+					t.Errorf("problem %d = %q, want it to mention %q", i, got[i], w)
+				}
+			}
+		})
+	}
+}
+
+func TestCommitRulesRepair(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules CommitRules
+		msg   string
+		want  string
+	}{
+		{name: "adds a type", msg: "Parse renames", want: "chore: parse renames"},
+		{name: "replaces a disallowed type", rules: CommitRules{Types: []string{"feat", "fix", "chore"}}, msg: "docs: explain flags", want: "chore: explain flags"},
+		{name: "falls back to the first type", rules: CommitRules{Types: []string{"feat", "fix"}}, msg: "docs: explain flags", want: "feat: explain flags"},
+		{name: "shortens at a word", rules: CommitRules{MaxSubject: 20}, msg: "fix: handle very long titles gracefully", want: "fix: handle very"},
+		{name: "to gitmoji", rules: CommitRules{Style: "gitmoji"}, msg: "fix: stop the crash", want: "🐛 Stop the crash"},
+		{name: "from gitmoji", msg: "✨ Add export", want: "feat: add export"},
+		{name: "to short drops the body", rules: CommitRules{Style: "short"}, msg: "feat: add export\n\nLong story.", want: "Add export"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.rules.Repair(tt.msg)
+			if got != tt.want {
+				t.Errorf("Repair(%q) = %q, want %q", tt.msg, got, tt.want)
+			}
+			if problems := tt.rules.Check(got); len(problems) > 0 {
+				t.Errorf("repaired message still breaks the rules: %q", problems)
+			}
+		})
+	}
+}
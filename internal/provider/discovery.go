@@ -0,0 +1,122 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ModelLister is implemented by providers that can enumerate their models.
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]string, error)
+}
+
+// DefaultModels are offered when a provider cannot be asked (no key yet,
+// offline). They follow the spec's initial model list.
+var DefaultModels = map[string][]string{
+	"openai":    {"gpt-4.1", "gpt-4o", "gpt-4o-mini"},
+	"gemini":    {"gemini-1.5-pro", "gemini-1.5-flash"},
+	"anthropic": {"claude-3-opus", "claude-3-sonnet"},
+	"ollama":    {"llama3", "mistral"},
+}
+
+// modelCacheTTL is how long a discovered model list is trusted.
+const modelCacheTTL = 24 * time.Hour
+
+type modelCacheEntry struct {
+	Fetched time.Time `json:"fetched"`
+	Models  []string  `json:"models"`
+}
+
+var modelCacheMu sync.Mutex
+
+func modelCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ai-git", "models.json"), nil
+}
+
+func loadModelCache() map[string]modelCacheEntry {
+	entries := make(map[string]modelCacheEntry)
+	path, err := modelCachePath()
+	if err != nil {
+		return entries
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &entries)
+	}
+	return entries
+}
+
+// CachedModels returns the last discovered model list for key without
+// touching the network. ok is false if there is none or it has expired.
+func CachedModels(key string) (models []string, ok bool) {
+	modelCacheMu.Lock()
+	defer modelCacheMu.Unlock()
+	entry, ok := loadModelCache()[key]
+	if !ok || time.Since(entry.Fetched) > modelCacheTTL {
+		return nil, false
+	}
+	return entry.Models, true
+}
+
+func saveCachedModels(key string, models []string) error {
+	modelCacheMu.Lock()
+	defer modelCacheMu.Unlock()
+	path, err := modelCachePath()
+	if err != nil {
+		return err
+	}
+	entries := loadModelCache()
+	entries[key] = modelCacheEntry{Fetched: time.Now(), Models: models}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// DiscoverModels returns the models p offers, from the cache under key
+// unless refresh is set or the entry has expired.
+func DiscoverModels(ctx context.Context, p Provider, key string, refresh bool) ([]string, error) {
+	if !refresh {
+		if models, ok := CachedModels(key); ok {
+			return models, nil
+		}
+	}
+	lister, ok := p.(ModelLister)
+	if !ok {
+		return nil, fmt.Errorf("provider %s cannot list its models", p.GetName())
+	}
+	models, err := lister.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(models)
+	saveCachedModels(key, models)
+	return models, nil
+}
+
+// HasModel reports whether model is among known, treating an Ollama name
+// without a tag as ":latest" and ignoring Gemini's "models/" prefix.
+func HasModel(known []string, model string) bool {
+	want := strings.TrimPrefix(model, "models/")
+	for _, m := range known {
+		m = strings.TrimPrefix(m, "models/")
+		if m == want || m == want+":latest" || strings.TrimSuffix(m, ":latest") == want {
+			return true
+		}
+	}
+	return false
+}
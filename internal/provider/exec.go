@@ -0,0 +1,487 @@
+package provider
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eliau2005/ai-git/internal/config"
+	"github.com/eliau2005/ai-git/internal/prompt"
+)
+
+// ExecProvider runs an external program as a provider plugin, talking
+// JSON lines over its stdin and stdout. Every request carries an id and
+// an op; the plugin answers with the same id:
+//
+//	→ {"id":1,"op":"capabilities"}
+//	← {"id":1,"result":{"capabilities":["generate","chat-stream","embed"]}}
+//	→ {"id":2,"op":"generate","params":{"model":"m","system":"...","messages":[{"role":"user","content":"..."}]}}
+//	← {"id":2,"result":"feat: add login page"}
+//	→ {"id":3,"op":"chat-stream","params":{"model":"m","system":"...","messages":[...],"prompt":"...","context":"..."}}
+//	← {"id":3,"chunk":"Hello"}
+//	← {"id":3,"result":null}
+//	→ {"id":4,"op":"cancel","params":{"id":3}}
+//
+// generate and chat-stream also carry "generation" with any configured
+// temperature, top_p, max_tokens, stop, context_window and options.
+// Other ops: embed {model, text} → [floats], resolve {model, content} and
+// refactor {model, prompt, content} → string. Failures are reported as
+// {"id":N,"error":"message"}. Requests may be in flight concurrently, and
+// the plugin should exit when stdin is closed.
+//
+// The factory starts the plugin and asks for its capabilities when it
+// builds the provider, so a broken plugin is reported up front (see
+// StartError). Close, or ClosePlugins at the end of a command, stops it.
+type ExecProvider struct {
+	Name           string
+	Command        string
+	Args           []string
+	Model          string
+	EmbeddingModel string
+	SystemPrompt   string
+	CommitPrompt   string
+	Params         config.GenerationConfig
+
+	once         sync.Once
+	startErr     error
+	capabilities map[string]bool
+
+	// writeMu serializes writes to stdin. It is never held together with
+	// mu, which readLoop needs to route answers while a write blocks.
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	exited  chan struct{} // closed once the process has been reaped
+	nextID  int
+	pending map[int]*execCall
+	exitErr error
+	stderr  *tailBuffer
+}
+
+const (
+	// pluginStartTimeout bounds capability discovery, so a plugin that
+	// never answers can't hang every command.
+	pluginStartTimeout = 10 * time.Second
+	// pluginStopTimeout is how long Close waits for a plugin to exit
+	// before killing it.
+	pluginStopTimeout = 2 * time.Second
+)
+
+var errPluginClosed = errors.New("plugin closed")
+
+// plugins are the plugin processes started so far, for ClosePlugins.
+var plugins struct {
+	mu      sync.Mutex
+	started []*ExecProvider
+}
+
+// ClosePlugins stops every plugin process this run started.
+func ClosePlugins() {
+	plugins.mu.Lock()
+	started := plugins.started
+	plugins.started = nil
+	plugins.mu.Unlock()
+	for _, p := range started {
+		p.Close()
+	}
+}
+
+// execCall is a request awaiting its answer; done is closed when the
+// caller stops listening, so the reader never blocks on it.
+type execCall struct {
+	responses chan execResponse
+	done      chan struct{}
+}
+
+type execRequest struct {
+	ID     int    `json:"id"`
+	Op     string `json:"op"`
+	Params any    `json:"params,omitempty"`
+}
+
+type execResponse struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Chunk  *string         `json:"chunk,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+type execMessagesParams struct {
+	Model      string                   `json:"model"`
+	System     string                   `json:"system,omitempty"`
+	Messages   []Message                `json:"messages"`
+	Generation *config.GenerationConfig `json:"generation,omitempty"`
+}
+
+type execChatParams struct {
+	Model      string                   `json:"model"`
+	System     string                   `json:"system,omitempty"`
+	Messages   []Message                `json:"messages"`
+	Prompt     string                   `json:"prompt"` // the last message, for plugins that ignore history
+	Context    string                   `json:"context,omitempty"`
+	Generation *config.GenerationConfig `json:"generation,omitempty"`
+}
+
+type execEmbedParams struct {
+	Model string `json:"model,omitempty"`
+	Text  string `json:"text"`
+}
+
+type execResolveParams struct {
+	Model   string `json:"model"`
+	Content string `json:"content"`
+}
+
+type execRefactorParams struct {
+	Model   string `json:"model"`
+	Prompt  string `json:"prompt"`
+	Content string `json:"content"`
+}
+
+func (p *ExecProvider) GetName() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return "exec"
+}
+
+// Start launches the plugin and asks for its capabilities. It runs once;
+// later calls return the first outcome.
+func (p *ExecProvider) Start() error {
+	p.once.Do(func() {
+		p.startErr = p.start()
+	})
+	return p.startErr
+}
+
+func (p *ExecProvider) start() error {
+	name, args := p.Command, p.Args
+	if len(args) == 0 {
+		fields := strings.Fields(p.Command)
+		if len(fields) == 0 {
+			return fmt.Errorf("provider %s: no command configured", p.GetName())
+		}
+		name, args = fields[0], fields[1:]
+	}
+
+	cmd := exec.Command(name, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	p.stderr = &tailBuffer{max: 4096}
+	cmd.Stderr = p.stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("provider %s: failed to start %s: %w", p.GetName(), name, err)
+	}
+
+	p.mu.Lock()
+	p.cmd = cmd
+	p.stdin = stdin
+	p.exited = make(chan struct{})
+	p.pending = make(map[int]*execCall)
+	p.mu.Unlock()
+	go p.readLoop(cmd, stdout)
+
+	plugins.mu.Lock()
+	plugins.started = append(plugins.started, p)
+	plugins.mu.Unlock()
+
+	var caps struct {
+		Capabilities []string `json:"capabilities"`
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pluginStartTimeout)
+	defer cancel()
+	if err := p.call(ctx, "capabilities", nil, nil, &caps); err != nil {
+		cmd.Process.Kill()
+		return fmt.Errorf("provider %s: capability discovery failed: %w", p.GetName(), err)
+	}
+	p.capabilities = make(map[string]bool)
+	for _, c := range caps.Capabilities {
+		p.capabilities[c] = true
+	}
+	return nil
+}
+
+// readLoop routes every line from the plugin to the request waiting for it.
+func (p *ExecProvider) readLoop(cmd *exec.Cmd, stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var resp execResponse
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			continue
+		}
+		p.mu.Lock()
+		c := p.pending[resp.ID]
+		p.mu.Unlock()
+		if c != nil {
+			select {
+			case c.responses <- resp:
+			case <-c.done:
+			}
+		}
+	}
+
+	err := cmd.Wait()
+	if err == nil {
+		err = errors.New("plugin exited")
+	}
+	if tail := strings.TrimSpace(p.stderr.String()); tail != "" {
+		err = fmt.Errorf("%w: %s", err, tail)
+	}
+	p.mu.Lock()
+	p.exitErr = err
+	for id, c := range p.pending {
+		close(c.responses)
+		delete(p.pending, id)
+	}
+	p.mu.Unlock()
+	close(p.exited)
+}
+
+// Close closes the plugin's stdin and waits for it to exit, killing it
+// after pluginStopTimeout. A plugin that was never used is not started.
+func (p *ExecProvider) Close() error {
+	p.once.Do(func() {
+		p.startErr = fmt.Errorf("provider %s: %w", p.GetName(), errPluginClosed)
+	})
+	p.mu.Lock()
+	cmd, stdin, exited := p.cmd, p.stdin, p.exited
+	p.mu.Unlock()
+	if cmd == nil {
+		return nil
+	}
+	stdin.Close()
+	select {
+	case <-exited:
+	case <-time.After(pluginStopTimeout):
+		cmd.Process.Kill()
+		<-exited
+	}
+	return nil
+}
+
+// call sends one request and waits for its result, passing streamed
+// chunks to onChunk. result may be nil when the answer carries no value.
+func (p *ExecProvider) call(ctx context.Context, op string, params any, onChunk func(string), result any) error {
+	p.mu.Lock()
+	if p.exitErr != nil {
+		p.mu.Unlock()
+		return fmt.Errorf("provider %s: %w", p.GetName(), p.exitErr)
+	}
+	p.nextID++
+	id := p.nextID
+	c := &execCall{responses: make(chan execResponse, 16), done: make(chan struct{})}
+	p.pending[id] = c
+	p.mu.Unlock()
+	err := p.send(execRequest{ID: id, Op: op, Params: params})
+
+	defer func() {
+		p.mu.Lock()
+		delete(p.pending, id)
+		p.mu.Unlock()
+		close(c.done)
+	}()
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.send(execRequest{Op: "cancel", Params: map[string]int{"id": id}})
+			return ctx.Err()
+		case resp, ok := <-c.responses:
+			if !ok {
+				p.mu.Lock()
+				exitErr := p.exitErr
+				p.mu.Unlock()
+				return fmt.Errorf("provider %s: %w", p.GetName(), exitErr)
+			}
+			if resp.Chunk != nil {
+				if onChunk != nil {
+					onChunk(*resp.Chunk)
+				}
+				continue
+			}
+			if resp.Error != "" {
+				return fmt.Errorf("provider %s: %s", p.GetName(), resp.Error)
+			}
+			if result == nil || len(resp.Result) == 0 {
+				return nil
+			}
+			return json.Unmarshal(resp.Result, result)
+		}
+	}
+}
+
+// send writes one request line. It must not be called with p.mu held.
+func (p *ExecProvider) send(req execRequest) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	_, err = p.stdin.Write(append(data, '\n'))
+	return err
+}
+
+// Capabilities returns the ops the plugin announced, sorted.
+func (p *ExecProvider) Capabilities() []string {
+	if p.Start() != nil {
+		return nil
+	}
+	caps := make([]string, 0, len(p.capabilities))
+	for c := range p.capabilities {
+		caps = append(caps, c)
+	}
+	sort.Strings(caps)
+	return caps
+}
+
+// Supports reports whether the plugin announced the capability.
+func (p *ExecProvider) Supports(op string) bool {
+	return p.Start() == nil && p.capabilities[op]
+}
+
+func (p *ExecProvider) require(op string, what string) error {
+	if err := p.Start(); err != nil {
+		return err
+	}
+	if !p.capabilities[op] {
+		return fmt.Errorf("provider %s does not support %s", p.GetName(), what)
+	}
+	return nil
+}
+
+func (p *ExecProvider) GenerateCommitMessage(ctx context.Context, diff string, contextStr string) (string, error) {
+	systemPrompt := p.SystemPrompt
+	if systemPrompt == "" {
+		systemPrompt = prompt.DefaultSystem
+	}
+	userPrompt, err := commitPrompt(ctx, p.CommitPrompt, diff, contextStr)
+	if err != nil {
+		return "", err
+	}
+	return p.Complete(ctx, systemPrompt, []Message{{Role: "user", Content: userPrompt}})
+}
+
+func (p *ExecProvider) Complete(ctx context.Context, system string, messages []Message) (string, error) {
+	if err := p.require("generate", "text generation"); err != nil {
+		return "", err
+	}
+	var out string
+	err := p.call(ctx, "generate", execMessagesParams{Model: p.Model, System: system, Messages: messages, Generation: p.generation()}, nil, &out)
+	return out, err
+}
+
+// AskChatStream falls back to a single generate call, delivered as one
+// chunk, for plugins that cannot stream.
+func (p *ExecProvider) AskChatStream(ctx context.Context, messages []Message, contextStr string, onChunk func(string)) error {
+	if err := p.Start(); err != nil {
+		return err
+	}
+	if !p.capabilities["chat-stream"] {
+		out, err := p.Complete(ctx, p.SystemPrompt, chatMessages(messages, contextStr))
+		if err == nil {
+			onChunk(out)
+		}
+		return err
+	}
+	params := execChatParams{Model: p.Model, System: p.SystemPrompt, Messages: messages, Context: contextStr, Generation: p.generation()}
+	if len(messages) > 0 {
+		params.Prompt = messages[len(messages)-1].Content
+	}
+	return p.call(ctx, "chat-stream", params, onChunk, nil)
+}
+
+func (p *ExecProvider) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	if err := p.require("embed", "embeddings"); err != nil {
+		return nil, err
+	}
+	var out []float32
+	err := p.call(ctx, "embed", execEmbedParams{Model: p.EmbeddingModel, Text: text}, nil, &out)
+	return out, err
+}
+
+// ListModels asks plugins that announce the "models" capability.
+func (p *ExecProvider) ListModels(ctx context.Context) ([]string, error) {
+	if err := p.require("models", "model discovery"); err != nil {
+		return nil, err
+	}
+	var models []string
+	err := p.call(ctx, "models", nil, nil, &models)
+	return models, err
+}
+
+// ResolveConflict and RefactorCode use the plugin's own operation when it
+// has one and the shared generate-based prompts otherwise.
+func (p *ExecProvider) ResolveConflict(ctx context.Context, fileContent string) (string, error) {
+	if !p.Supports("resolve") {
+		return resolveConflict(ctx, p, fileContent)
+	}
+	var out string
+	if err := p.call(ctx, "resolve", execResolveParams{Model: p.Model, Content: fileContent}, nil, &out); err != nil {
+		return "", err
+	}
+	return stripCodeFence(out), nil
+}
+
+func (p *ExecProvider) RefactorCode(ctx context.Context, prompt string, fileContent string) (string, error) {
+	if !p.Supports("refactor") {
+		return refactorCode(ctx, p, prompt, fileContent)
+	}
+	var out string
+	if err := p.call(ctx, "refactor", execRefactorParams{Model: p.Model, Prompt: prompt, Content: fileContent}, nil, &out); err != nil {
+		return "", err
+	}
+	return stripCodeFence(out), nil
+}
+
+// generation returns the parameters to send, or nil when none are set.
+func (p *ExecProvider) generation() *config.GenerationConfig {
+	g := p.Params
+	if g.Temperature == nil && g.TopP == nil && g.MaxTokens == 0 && g.Stop == nil && g.ContextWindow == 0 && len(g.Options) == 0 {
+		return nil
+	}
+	return &g
+}
+
+// tailBuffer keeps the last max bytes written, for plugin stderr in errors.
+type tailBuffer struct {
+	mu  sync.Mutex
+	max int
+	buf []byte
+}
+
+func (b *tailBuffer) Write(data []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, data...)
+	if len(b.buf) > b.max {
+		b.buf = b.buf[len(b.buf)-b.max:]
+	}
+	return len(data), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
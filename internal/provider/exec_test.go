@@ -0,0 +1,111 @@
+package provider
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eliau2005/ai-git/internal/config"
+)
+
+const pluginEnv = "AI_GIT_TEST_PLUGIN"
+
+// TestMain turns the test binary into a provider plugin when pluginEnv is
+// set. The plugin answers one request at a time: generate echoes the last
+// message, so big requests get big answers.
+func TestMain(m *testing.M) {
+	switch os.Getenv(pluginEnv) {
+	case "":
+		os.Exit(m.Run())
+	case "silent":
+		os.Exit(1)
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	out := json.NewEncoder(os.Stdout)
+	for scanner.Scan() {
+		var req struct {
+			ID     int                `json:"id"`
+			Op     string             `json:"op"`
+			Params execMessagesParams `json:"params"`
+		}
+		json.Unmarshal(scanner.Bytes(), &req)
+		switch req.Op {
+		case "capabilities":
+			out.Encode(map[string]any{"id": req.ID, "result": map[string]any{"capabilities": []string{"generate", "embed"}}})
+		case "generate":
+			out.Encode(map[string]any{"id": req.ID, "result": req.Params.Messages[len(req.Params.Messages)-1].Content})
+		}
+	}
+	os.Exit(0)
+}
+
+func testPlugin(t *testing.T, mode string) Provider {
+	t.Helper()
+	t.Setenv(pluginEnv, mode)
+	p := (&ProviderFactory{}).GetProvider("plugin", config.ProviderConfig{Type: "exec", Command: os.Args[0], Args: []string{"-test.run=^$"}}, "m", "", "")
+	if e, ok := p.(*ExecProvider); ok {
+		t.Cleanup(func() { e.Close() })
+	}
+	return p
+}
+
+func TestExecHandshakeAtBuild(t *testing.T) {
+	p := testPlugin(t, "serve")
+	if err := StartError(p); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.(*ExecProvider).Capabilities(); strings.Join(got, ",") != "embed,generate" {
+		t.Errorf("capabilities = %q", got)
+	}
+
+	broken := testPlugin(t, "silent")
+	if err := StartError(broken); err == nil || !strings.Contains(err.Error(), "capability discovery failed") {
+		t.Errorf("a plugin that exits at once got %v", err)
+	}
+
+	missing := (&ProviderFactory{}).GetProvider("missing", config.ProviderConfig{Type: "exec", Command: "/nonexistent/ai-git-plugin"}, "m", "", "")
+	if err := StartError(missing); err == nil || !strings.Contains(err.Error(), "failed to start") {
+		t.Errorf("a missing command got %v", err)
+	}
+}
+
+// TestExecConcurrentLargeRequests keeps both pipes full at once: requests
+// are written while the plugin blocks on its answers, which only drain if
+// a write in progress doesn't stop the reader from routing them.
+func TestExecConcurrentLargeRequests(t *testing.T) {
+	p := testPlugin(t, "serve").(*ExecProvider)
+	if err := StartError(p); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			msg := fmt.Sprintf("%d:%s", i, strings.Repeat("x", 512*1024))
+			got, err := p.Complete(ctx, "", []Message{{Role: "user", Content: msg}})
+			if err == nil && got != msg {
+				err = fmt.Errorf("request %d got %d bytes back, want %d", i, len(got), len(msg))
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package provider
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"sync"
+)
+
+const fakeDefaultResponse = "chore: update files\n\nScripted response from the fake provider."
+
+// fakeEmbeddingSize is small but enough for bag-of-words similarity to rank sensibly.
+const fakeEmbeddingSize = 64
+
+// FakeProvider answers from a script instead of a model, for offline runs
+// and deterministic tests. Responses are served in order and the last one
+// repeats once the script runs out.
+type FakeProvider struct {
+	Name      string
+	Responses []string
+
+	mu      sync.Mutex
+	next    int
+	prompts []string
+}
+
+func (p *FakeProvider) GetName() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return "fake"
+}
+
+// Prompts returns the last user message of every call made so far.
+func (p *FakeProvider) Prompts() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.prompts...)
+}
+
+func (p *FakeProvider) respond(prompt string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.prompts = append(p.prompts, prompt)
+	if len(p.Responses) == 0 {
+		return fakeDefaultResponse
+	}
+	resp := p.Responses[min(p.next, len(p.Responses)-1)]
+	p.next++
+	return resp
+}
+
+func (p *FakeProvider) GenerateCommitMessage(ctx context.Context, diff string, contextStr string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	userPrompt, err := commitPrompt(ctx, "", diff, contextStr)
+	if err != nil {
+		return "", err
+	}
+	return p.respond(userPrompt), nil
+}
+
+func (p *FakeProvider) Complete(ctx context.Context, system string, messages []Message) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	var prompt string
+	if len(messages) > 0 {
+		prompt = messages[len(messages)-1].Content
+	}
+	return p.respond(prompt), nil
+}
+
+func (p *FakeProvider) AskChatStream(ctx context.Context, messages []Message, contextStr string, onChunk func(string)) error {
+	var prompt string
+	if len(messages) > 0 {
+		prompt = messages[len(messages)-1].Content
+	}
+	for _, word := range strings.SplitAfter(p.respond(prompt), " ") {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		onChunk(word)
+	}
+	return nil
+}
+
+// GenerateEmbedding hashes words into a fixed-size, normalized vector, so
+// texts sharing vocabulary score as similar.
+func (p *FakeProvider) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	vec := make([]float32, fakeEmbeddingSize)
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		h := fnv.New32a()
+		h.Write([]byte(word))
+		vec[h.Sum32()%fakeEmbeddingSize]++
+	}
+	var norm float64
+	for _, v := range vec {
+		norm += float64(v * v)
+	}
+	if norm > 0 {
+		scale := float32(1 / math.Sqrt(norm))
+		for i := range vec {
+			vec[i] *= scale
+		}
+	}
+	return vec, nil
+}
+
+func (p *FakeProvider) ResolveConflict(ctx context.Context, fileContent string) (string, error) {
+	return resolveConflict(ctx, p, fileContent)
+}
+
+func (p *FakeProvider) RefactorCode(ctx context.Context, prompt string, fileContent string) (string, error) {
+	return refactorCode(ctx, p, prompt, fileContent)
+}
@@ -0,0 +1,194 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// FallbackProvider tries each provider in order until one succeeds.
+// Members that lack a capability are skipped for that call. A cancelled
+// context is never treated as a provider failure.
+type FallbackProvider struct {
+	Providers []Provider
+
+	mu       sync.Mutex
+	used     string
+	failures []string
+	embedder Embedder // pinned after the first successful embedding
+}
+
+func (f *FallbackProvider) GetName() string {
+	return f.Providers[0].GetName()
+}
+
+// Used returns the name of the provider that produced the last result,
+// and the failures of any providers tried before it.
+func (f *FallbackProvider) Used() (string, []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.used, f.failures
+}
+
+// try runs call against each member that supports the capability, in order.
+func (f *FallbackProvider) try(ctx context.Context, capability string, call func(p Provider) (bool, error)) error {
+	var failures []string
+	for _, p := range f.Providers {
+		supported, err := call(p)
+		if !supported {
+			continue
+		}
+		if err == nil {
+			f.mu.Lock()
+			f.used = p.GetName()
+			f.failures = failures
+			f.mu.Unlock()
+			return nil
+		}
+		if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+			return err
+		}
+		failures = append(failures, fmt.Sprintf("%s: %v", p.GetName(), err))
+	}
+	if len(failures) == 0 {
+		return fmt.Errorf("no configured provider supports %s", capability)
+	}
+	return fmt.Errorf("all providers failed:\n  %s", strings.Join(failures, "\n  "))
+}
+
+func (f *FallbackProvider) GenerateCommitMessage(ctx context.Context, diff string, contextStr string) (string, error) {
+	var out string
+	err := f.try(ctx, "commit messages", func(p Provider) (bool, error) {
+		var err error
+		out, err = p.GenerateCommitMessage(ctx, diff, contextStr)
+		return true, err
+	})
+	return out, err
+}
+
+func (f *FallbackProvider) GenerateCommitMessages(ctx context.Context, diff string, contextStr string, n int) ([]string, error) {
+	var out []string
+	err := f.try(ctx, "commit messages", func(p Provider) (bool, error) {
+		var err error
+		out, err = GenerateCandidates(ctx, p, diff, contextStr, n)
+		return true, err
+	})
+	return out, err
+}
+
+func (f *FallbackProvider) Complete(ctx context.Context, system string, messages []Message) (string, error) {
+	var out string
+	err := f.try(ctx, "completions", func(p Provider) (bool, error) {
+		c, ok := p.(Completer)
+		if !ok {
+			return false, nil
+		}
+		var err error
+		out, err = c.Complete(ctx, system, messages)
+		return true, err
+	})
+	return out, err
+}
+
+// CompleteJSON uses each member's JSON mode, or a plain completion for
+// members without one; callers parse the reply tolerantly either way.
+func (f *FallbackProvider) CompleteJSON(ctx context.Context, system string, messages []Message, schema map[string]any) (string, error) {
+	var out string
+	err := f.try(ctx, "completions", func(p Provider) (bool, error) {
+		var err error
+		switch c := p.(type) {
+		case JSONCompleter:
+			out, err = c.CompleteJSON(ctx, system, messages, schema)
+		case Completer:
+			out, err = c.Complete(ctx, system, messages)
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+	return out, err
+}
+
+// AskChatStream only falls back while nothing has been streamed yet;
+// once a provider has started answering, its error is returned as is.
+func (f *FallbackProvider) AskChatStream(ctx context.Context, messages []Message, contextStr string, onChunk func(string)) error {
+	streamed := false
+	var streamErr error
+	err := f.try(ctx, "chat", func(p Provider) (bool, error) {
+		c, ok := p.(Chatter)
+		if !ok {
+			return false, nil
+		}
+		err := c.AskChatStream(ctx, messages, contextStr, func(chunk string) {
+			streamed = true
+			onChunk(chunk)
+		})
+		if err != nil && streamed {
+			streamErr = err
+			return true, nil
+		}
+		return true, err
+	})
+	if streamErr != nil {
+		return streamErr
+	}
+	return err
+}
+
+// GenerateEmbedding sticks with the first provider that succeeds, since
+// vectors from different embedding models cannot be compared.
+func (f *FallbackProvider) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	f.mu.Lock()
+	pinned := f.embedder
+	f.mu.Unlock()
+	if pinned != nil {
+		return pinned.GenerateEmbedding(ctx, text)
+	}
+
+	var out []float32
+	err := f.try(ctx, "embeddings", func(p Provider) (bool, error) {
+		e, ok := p.(Embedder)
+		if !ok {
+			return false, nil
+		}
+		var err error
+		out, err = e.GenerateEmbedding(ctx, text)
+		if err == nil {
+			f.mu.Lock()
+			f.embedder = e
+			f.mu.Unlock()
+		}
+		return true, err
+	})
+	return out, err
+}
+
+func (f *FallbackProvider) ResolveConflict(ctx context.Context, fileContent string) (string, error) {
+	var out string
+	err := f.try(ctx, "conflict resolution", func(p Provider) (bool, error) {
+		r, ok := p.(ConflictResolver)
+		if !ok {
+			return false, nil
+		}
+		var err error
+		out, err = r.ResolveConflict(ctx, fileContent)
+		return true, err
+	})
+	return out, err
+}
+
+func (f *FallbackProvider) RefactorCode(ctx context.Context, prompt string, fileContent string) (string, error) {
+	var out string
+	err := f.try(ctx, "refactoring", func(p Provider) (bool, error) {
+		r, ok := p.(CodeRefactorer)
+		if !ok {
+			return false, nil
+		}
+		var err error
+		out, err = r.RefactorCode(ctx, prompt, fileContent)
+		return true, err
+	})
+	return out, err
+}
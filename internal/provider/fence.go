@@ -0,0 +1,23 @@
+package provider
+
+import "strings"
+
+// stripCodeFence removes a markdown code fence wrapped around a model's
+// answer (```go ... ```), for when the AI ignores the "raw code" instruction.
+func stripCodeFence(text string) string {
+	trimmed := strings.TrimSpace(text)
+	if !strings.HasPrefix(trimmed, "```") {
+		return text
+	}
+	nl := strings.Index(trimmed, "\n")
+	if nl == -1 {
+		return text
+	}
+	// The opening line may carry a language tag, e.g. ```typescript
+	if strings.ContainsAny(trimmed[3:nl], " \t`") {
+		return text
+	}
+	trimmed = trimmed[nl+1:]
+	trimmed = strings.TrimSuffix(trimmed, "```")
+	return strings.TrimSuffix(trimmed, "\n")
+}
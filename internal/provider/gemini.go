@@ -2,29 +2,56 @@ package provider
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
+
+	"github.com/eliau2005/ai-git/internal/config"
 )
 
 type GeminiProvider struct {
-	APIKey       string
-	Model        string
-	SystemPrompt string
-	CommitPrompt string
+	Name           string // config entry name; empty means "gemini"
+	APIKey         string
+	Model          string
+	EmbeddingModel string
+	SystemPrompt   string
+	CommitPrompt   string
+	Params         config.GenerationConfig
+	HTTPConfig
 }
 
 func (p *GeminiProvider) GetName() string {
+	if p.Name != "" {
+		return p.Name
+	}
 	return "gemini"
 }
 
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
 type geminiGenerateContentRequest struct {
-	Contents []struct {
-		Parts []struct {
-			Text string `json:"text"`
-		} `json:"parts"`
-	} `json:"contents"`
+	SystemInstruction *geminiContent          `json:"systemInstruction,omitempty"`
+	Contents          []geminiContent         `json:"contents"`
+	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type geminiGenerationConfig struct {
+	ResponseMimeType string         `json:"responseMimeType,omitempty"`
+	ResponseSchema   map[string]any `json:"responseSchema,omitempty"`
+	Temperature      *float64       `json:"temperature,omitempty"`
+	TopP             *float64       `json:"topP,omitempty"`
+	MaxOutputTokens  int            `json:"maxOutputTokens,omitempty"`
+	StopSequences    []string       `json:"stopSequences,omitempty"`
 }
 
 type geminiGenerateContentResponse struct {
@@ -35,6 +62,10 @@ type geminiGenerateContentResponse struct {
 			} `json:"parts"`
 		} `json:"content"`
 	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata"`
 	Error struct {
 		Code    int    `json:"code"`
 		Message string `json:"message"`
@@ -42,56 +73,51 @@ type geminiGenerateContentResponse struct {
 	} `json:"error,omitempty"`
 }
 
-func (p *GeminiProvider) GenerateCommitMessage(diff string, context string) (string, error) {
-	// Truncate diff
-	if len(diff) > 15000 {
-		diff = diff[:15000] + "\n... [Diff truncated] ..."
-	}
-
-	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent?key=%s", p.Model, p.APIKey)
-
-	commitPromptTemplate := p.CommitPrompt
-	if commitPromptTemplate == "" {
-		commitPromptTemplate = "Generate a raw git commit message for the changes below. Output ONLY the message. Structure: a short title, then a blank line, then a description. No conversational filler, no quotes, no backticks.\n\nChanges:\n%s\n\n%s"
+func (p *GeminiProvider) GenerateCommitMessage(ctx context.Context, diff string, contextStr string) (string, error) {
+	prompt, err := commitPrompt(ctx, p.CommitPrompt, diff, contextStr)
+	if err != nil {
+		return "", err
 	}
 
 	// Incorporate SystemPrompt into the user prompt for Gemini as it doesn't strictly have a separate system role in the same way (or it's complex to structure for v1beta simple calls)
 	systemPrompt := p.SystemPrompt
 	if systemPrompt != "" {
-		commitPromptTemplate = systemPrompt + "\n\n" + commitPromptTemplate
+		prompt = systemPrompt + "\n\n" + prompt
 	}
 
-	prompt := fmt.Sprintf(commitPromptTemplate, diff, context)
+	return p.Complete(ctx, "", []Message{{Role: "user", Content: prompt}})
+}
 
-	reqBody := geminiGenerateContentRequest{
-		Contents: []struct {
-			Parts []struct {
-				Text string `json:"text"`
-			} `json:"parts"`
-		}{
-			{
-				Parts: []struct {
-					Text string `json:"text"`
-				}{
-					{Text: prompt},
-				},
-			},
-		},
+func (p *GeminiProvider) Complete(ctx context.Context, system string, messages []Message) (string, error) {
+	return p.generate(ctx, p.newRequest(system, messages))
+}
+
+func (p *GeminiProvider) CompleteJSON(ctx context.Context, system string, messages []Message, schema map[string]any) (string, error) {
+	reqBody := p.newRequest(system, messages)
+	if reqBody.GenerationConfig == nil {
+		reqBody.GenerationConfig = &geminiGenerationConfig{}
 	}
+	reqBody.GenerationConfig.ResponseMimeType = "application/json"
+	reqBody.GenerationConfig.ResponseSchema = geminiSchema(schema)
+	return p.generate(ctx, reqBody)
+}
+
+func (p *GeminiProvider) generate(ctx context.Context, reqBody geminiGenerateContentRequest) (string, error) {
+	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent?key=%s", p.Model, p.APIKey)
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		return "", err
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
+	client := p.httpClient()
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", err
@@ -102,9 +128,9 @@ func (p *GeminiProvider) GenerateCommitMessage(diff string, context string) (str
 		var errResp geminiGenerateContentResponse
 		body, _ := io.ReadAll(resp.Body)
 		if json.Unmarshal(body, &errResp) == nil && errResp.Error.Message != "" {
-			return "", fmt.Errorf("Gemini API error: %s (Status: %s)", errResp.Error.Message, errResp.Error.Status)
+			return "", apiError(resp, fmt.Errorf("Gemini API error: %s (Status: %s)", errResp.Error.Message, errResp.Error.Status))
 		}
-		return "", fmt.Errorf("Gemini API error: %s", string(body))
+		return "", apiError(resp, fmt.Errorf("Gemini API error: %s", string(body)))
 	}
 
 	var result geminiGenerateContentResponse
@@ -112,9 +138,53 @@ func (p *GeminiProvider) GenerateCommitMessage(diff string, context string) (str
 		return "", err
 	}
 
+	p.reportUsage("gemini", p.GetName(), p.Model, result.UsageMetadata.PromptTokenCount, result.UsageMetadata.CandidatesTokenCount)
+
 	if len(result.Candidates) > 0 && len(result.Candidates[0].Content.Parts) > 0 {
 		return result.Candidates[0].Content.Parts[0].Text, nil
 	}
 
 	return "", fmt.Errorf("no response from Gemini")
 }
+
+// geminiRequest maps our roles onto Gemini's ("assistant" is "model")
+// and moves the system prompt into systemInstruction.
+func geminiRequest(system string, messages []Message) geminiGenerateContentRequest {
+	var req geminiGenerateContentRequest
+	if system != "" {
+		req.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: system}}}
+	}
+	for _, m := range messages {
+		role := m.Role
+		if role == "assistant" {
+			role = "model"
+		}
+		req.Contents = append(req.Contents, geminiContent{Role: role, Parts: []geminiPart{{Text: m.Content}}})
+	}
+	return req
+}
+
+// geminiSchema converts a JSON schema to Gemini's OpenAPI subset, which
+// spells types in upper case and has no additionalProperties.
+func geminiSchema(schema map[string]any) map[string]any {
+	out := make(map[string]any, len(schema))
+	for k, v := range schema {
+		switch k {
+		case "additionalProperties":
+			continue
+		case "type":
+			out[k] = strings.ToUpper(v.(string))
+		case "properties":
+			props := make(map[string]any)
+			for name, sub := range v.(map[string]any) {
+				props[name] = geminiSchema(sub.(map[string]any))
+			}
+			out[k] = props
+		case "items":
+			out[k] = geminiSchema(v.(map[string]any))
+		default:
+			out[k] = v
+		}
+	}
+	return out
+}
@@ -3,55 +3,32 @@ package provider
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
-	"time"
 )
 
-func (p *GeminiProvider) AskChatStream(prompt string, contextStr string, onChunk func(string)) error {
+func (p *GeminiProvider) AskChatStream(ctx context.Context, messages []Message, contextStr string, onChunk func(string)) error {
 	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:streamGenerateContent?alt=sse&key=%s", p.Model, p.APIKey)
 
-	fullPrompt := prompt
-	if contextStr != "" {
-		fullPrompt = fmt.Sprintf("Context:\n%s\n\nQuestion:\n%s", contextStr, prompt)
-	}
-	if p.SystemPrompt != "" {
-		fullPrompt = p.SystemPrompt + "\n\n" + fullPrompt
-	}
-
-	reqBody := geminiGenerateContentRequest{
-		Contents: []struct {
-			Parts []struct {
-				Text string `json:"text"`
-			} `json:"parts"`
-		}{
-			{
-				Parts: []struct {
-					Text string `json:"text"`
-				}{
-					{Text: fullPrompt},
-				},
-			},
-		},
-	}
+	reqBody := p.newRequest(p.SystemPrompt, chatMessages(messages, contextStr))
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
-	// We use a longer timeout for streaming
-	client := &http.Client{Timeout: 60 * time.Second}
+	client := p.streamingClient()
 	resp, err := client.Do(req)
 	if err != nil {
 		return err
@@ -62,11 +39,17 @@ func (p *GeminiProvider) AskChatStream(prompt string, contextStr string, onChunk
 		var errResp geminiGenerateContentResponse
 		body, _ := io.ReadAll(resp.Body)
 		if json.Unmarshal(body, &errResp) == nil && errResp.Error.Message != "" {
-			return fmt.Errorf("Gemini API error: %s", errResp.Error.Message)
+			return apiError(resp, fmt.Errorf("Gemini API error: %s", errResp.Error.Message))
 		}
-		return fmt.Errorf("Gemini API error: %s", string(body))
+		return apiError(resp, fmt.Errorf("Gemini API error: %s", string(body)))
 	}
 
+	// Every chunk carries cumulative usage; the last one is the total
+	var input, output int
+	defer func() {
+		p.reportUsage("gemini", p.GetName(), p.Model, input, output)
+	}()
+
 	scanner := bufio.NewScanner(resp.Body)
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -77,6 +60,10 @@ func (p *GeminiProvider) AskChatStream(prompt string, contextStr string, onChunk
 			}
 			var chunkResp geminiGenerateContentResponse
 			if err := json.Unmarshal([]byte(data), &chunkResp); err == nil {
+				if chunkResp.UsageMetadata.PromptTokenCount > 0 {
+					input = chunkResp.UsageMetadata.PromptTokenCount
+					output = chunkResp.UsageMetadata.CandidatesTokenCount
+				}
 				if len(chunkResp.Candidates) > 0 && len(chunkResp.Candidates[0].Content.Parts) > 0 {
 					text := chunkResp.Candidates[0].Content.Parts[0].Text
 					onChunk(text)
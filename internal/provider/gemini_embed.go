@@ -2,11 +2,11 @@ package provider
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"time"
 )
 
 type geminiEmbedRequest struct {
@@ -27,9 +27,11 @@ type geminiEmbedResponse struct {
 	} `json:"error,omitempty"`
 }
 
-func (p *GeminiProvider) GenerateEmbedding(text string) ([]float32, error) {
-	// Gemini uses gemini-embedding-2
-	model := "gemini-embedding-2"
+func (p *GeminiProvider) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	model := p.EmbeddingModel
+	if model == "" {
+		model = "gemini-embedding-2"
+	}
 	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:embedContent?key=%s", model, p.APIKey)
 
 	reqBody := geminiEmbedRequest{
@@ -46,14 +48,14 @@ func (p *GeminiProvider) GenerateEmbedding(text string) ([]float32, error) {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{Timeout: 15 * time.Second}
+	client := p.httpClient()
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
@@ -62,7 +64,7 @@ func (p *GeminiProvider) GenerateEmbedding(text string) ([]float32, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("Gemini Embed API error: %s", string(body))
+		return nil, apiError(resp, fmt.Errorf("Gemini Embed API error: %s", string(body)))
 	}
 
 	var result geminiEmbedResponse
@@ -0,0 +1,48 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ListModels returns the Gemini models that can generate content, without
+// the "models/" prefix.
+func (p *GeminiProvider) ListModels(ctx context.Context) ([]string, error) {
+	var models []string
+	pageToken := ""
+	for {
+		url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models?pageSize=1000&key=%s", p.APIKey)
+		if pageToken != "" {
+			url += "&pageToken=" + pageToken
+		}
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var result struct {
+			Models []struct {
+				Name                       string   `json:"name"`
+				SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+			} `json:"models"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if err := getJSON(ctx, p.httpClient(), req, "Gemini", &result); err != nil {
+			return nil, err
+		}
+		for _, m := range result.Models {
+			for _, method := range m.SupportedGenerationMethods {
+				if method == "generateContent" {
+					models = append(models, strings.TrimPrefix(m.Name, "models/"))
+					break
+				}
+			}
+		}
+		if result.NextPageToken == "" {
+			return models, nil
+		}
+		pageToken = result.NextPageToken
+	}
+}
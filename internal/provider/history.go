@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/eliau2005/ai-git/internal/git"
+)
+
+const historySummaryPrompt = "You are condensing the start of a conversation between a developer and an assistant about their repository. " +
+	"Summarize it so the conversation can continue without it: keep the questions asked, the key facts from the answers, " +
+	"and every file, function and command name mentioned. Be concise and do not add anything new."
+
+// historyKeepMessages is how many of the latest messages CompactHistory
+// always keeps verbatim.
+const historyKeepMessages = 4
+
+// chatMessages attaches contextStr to the last message of a conversation.
+func chatMessages(messages []Message, contextStr string) []Message {
+	if contextStr == "" || len(messages) == 0 {
+		return messages
+	}
+	out := append([]Message(nil), messages...)
+	last := &out[len(out)-1]
+	last.Content = fmt.Sprintf("Context:\n%s\n\nQuestion:\n%s", contextStr, last.Content)
+	return out
+}
+
+// HistoryTokens estimates the size of a conversation in tokens.
+func HistoryTokens(messages []Message) int {
+	n := 0
+	for _, m := range messages {
+		n += git.EstimateTokens(m.Content)
+	}
+	return n
+}
+
+// CompactHistory keeps a conversation within budget tokens by replacing
+// its oldest turns with a summary written by c. The latest messages are
+// kept verbatim, and the result still starts with a user message and
+// alternates roles, as some APIs require.
+func CompactHistory(ctx context.Context, c Completer, messages []Message, budget int) ([]Message, error) {
+	if HistoryTokens(messages) <= budget || len(messages) <= historyKeepMessages {
+		return messages, nil
+	}
+	split := len(messages) - historyKeepMessages
+	for split > 0 && messages[split].Role != "user" {
+		split--
+	}
+	if split == 0 {
+		return messages, nil
+	}
+
+	var sb strings.Builder
+	for _, m := range messages[:split] {
+		sb.WriteString(fmt.Sprintf("%s: %s\n\n", m.Role, m.Content))
+	}
+	summary, err := c.Complete(ctx, historySummaryPrompt, []Message{{Role: "user", Content: sb.String()}})
+	if err != nil {
+		return nil, err
+	}
+
+	out := []Message{
+		{Role: "user", Content: "Summary of our conversation so far:\n" + strings.TrimSpace(summary)},
+		{Role: "assistant", Content: "Got it. What would you like to know next?"},
+	}
+	return append(out, messages[split:]...), nil
+}
@@ -0,0 +1,222 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultRequestTimeout applies when a provider has no `timeout` configured.
+const defaultRequestTimeout = 2 * time.Minute
+
+const (
+	defaultMaxRetries = 3
+	retryBaseDelay    = 1 * time.Second
+	// retryMaxDelay caps a single wait. A Retry-After longer than this
+	// (e.g. a daily quota) is not worth blocking on, so we give up and
+	// surface a RateLimitError instead.
+	retryMaxDelay = 30 * time.Second
+)
+
+// HTTPConfig holds the transport settings shared by every provider.
+type HTTPConfig struct {
+	Timeout time.Duration
+	// MaxRetries is the number of retries on 429/5xx; 0 means the default, negative disables retrying.
+	MaxRetries int
+	// Transport replaces the network when set, e.g. to record or replay cassettes.
+	Transport http.RoundTripper
+}
+
+// httpClient returns a client whose timeout covers the whole exchange,
+// for request/response calls where the body is small.
+func (c HTTPConfig) httpClient() *http.Client {
+	if c.Transport != nil {
+		return &http.Client{Timeout: c.timeout(), Transport: c.transport(c.Transport)}
+	}
+	return &http.Client{Timeout: c.timeout(), Transport: c.transport(http.DefaultTransport)}
+}
+
+// streamingClient only bounds the wait for response headers. Once tokens
+// start flowing the stream may run as long as it needs; callers stop it
+// by cancelling the request context.
+func (c HTTPConfig) streamingClient() *http.Client {
+	if c.Transport != nil {
+		return &http.Client{Transport: c.transport(c.Transport)}
+	}
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.ResponseHeaderTimeout = c.timeout()
+	return &http.Client{Transport: c.transport(base)}
+}
+
+func (c HTTPConfig) timeout() time.Duration {
+	if c.Timeout <= 0 {
+		return defaultRequestTimeout
+	}
+	return c.Timeout
+}
+
+func (c HTTPConfig) transport(base http.RoundTripper) http.RoundTripper {
+	retries := c.MaxRetries
+	if retries == 0 {
+		retries = defaultMaxRetries
+	}
+	if retries < 0 {
+		return base
+	}
+	return &retryTransport{base: base, maxRetries: retries}
+}
+
+// retryTransport retries throttled and transient failures with jittered
+// exponential backoff, honoring the server's Retry-After when present.
+type retryTransport struct {
+	base       http.RoundTripper
+	maxRetries int
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		if attempt > 0 && req.Body != nil {
+			if req.GetBody == nil {
+				return nil, fmt.Errorf("cannot retry request without GetBody")
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(req.Context())
+			attemptReq.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+		if attempt >= t.maxRetries || !shouldRetry(req.Context(), resp, err) {
+			return resp, err
+		}
+
+		wait := backoff(attempt)
+		if resp != nil {
+			if after, ok := parseRetryAfter(resp.Header); ok {
+				if after > retryMaxDelay {
+					return resp, nil
+				}
+				wait = after
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if err := sleepContext(req.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		// Network hiccups (reset connections, DNS blips) are worth another try
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout, 529: // 529: Anthropic "overloaded"
+		return true
+	}
+	return false
+}
+
+func backoff(attempt int) time.Duration {
+	// Stop doubling once the cap is reached; shifting further overflows
+	// into negative durations with a large max_retries
+	d := retryMaxDelay
+	if attempt < 16 {
+		d = min(retryBaseDelay<<attempt, retryMaxDelay)
+	}
+	// Full jitter keeps parallel clients from retrying in lockstep
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// parseRetryAfter understands both forms of the header: delay-seconds and an HTTP date.
+func parseRetryAfter(h http.Header) (time.Duration, bool) {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// getJSON sends req and decodes the JSON answer into out, reporting non-200 answers with the body.
+func getJSON(ctx context.Context, client *http.Client, req *http.Request, label string, out any) error {
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return apiError(resp, fmt.Errorf("%s API error: %s", label, string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// RateLimitError is returned when a provider keeps answering 429 after
+// retries are exhausted. RetryAfter is zero if the server gave no hint.
+type RateLimitError struct {
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *RateLimitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return e.Err
+}
+
+// IsRateLimit reports whether err is (or wraps) a RateLimitError.
+func IsRateLimit(err error) (*RateLimitError, bool) {
+	var rl *RateLimitError
+	if errors.As(err, &rl) {
+		return rl, true
+	}
+	return nil, false
+}
+
+// apiError types a non-200 response's error so callers can react to throttling.
+func apiError(resp *http.Response, err error) error {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		after, _ := parseRetryAfter(resp.Header)
+		return &RateLimitError{RetryAfter: after, Err: err}
+	}
+	return err
+}
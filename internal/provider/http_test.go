@@ -0,0 +1,63 @@
+package provider
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{0, retryBaseDelay / 2, retryBaseDelay},
+		{1, retryBaseDelay, 2 * retryBaseDelay},
+		{3, 4 * retryBaseDelay, 8 * retryBaseDelay},
+		{20, retryMaxDelay / 2, retryMaxDelay},
+		{64, retryMaxDelay / 2, retryMaxDelay},
+		{1000, retryMaxDelay / 2, retryMaxDelay},
+	}
+	for _, tt := range tests {
+		for range 20 {
+			d := backoff(tt.attempt)
+			if d < tt.min || d > tt.max {
+				t.Fatalf("backoff(%d) = %v, want between %v and %v", tt.attempt, d, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{name: "missing"},
+		{name: "seconds", header: "3", want: 3 * time.Second, ok: true},
+		{name: "zero", header: "0", ok: true},
+		{name: "negative", header: "-5"},
+		{name: "garbage", header: "soon"},
+		{name: "past date", header: "Wed, 21 Oct 2015 07:28:00 GMT", ok: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			if tt.header != "" {
+				h.Set("Retry-After", tt.header)
+			}
+			got, ok := parseRetryAfter(h)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tt.header, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+
+	h := http.Header{}
+	h.Set("Retry-After", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	if got, ok := parseRetryAfter(h); !ok || got <= 50*time.Second || got > time.Minute {
+		t.Errorf("parseRetryAfter(a minute from now) = %v, %v", got, ok)
+	}
+}
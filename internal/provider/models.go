@@ -0,0 +1,81 @@
+package provider
+
+import (
+	"strings"
+	"sync"
+)
+
+// contextWindows maps model name prefixes to their context size in tokens.
+// Longer prefixes are matched first, so "gpt-4o" wins over "gpt-4".
+var contextWindows = map[string]int{
+	"gpt-5":          400000,
+	"gpt-4.1":        1047576,
+	"gpt-4o":         128000,
+	"gpt-4-turbo":    128000,
+	"gpt-4":          8192,
+	"gpt-3.5-turbo":  16385,
+	"o1":             200000,
+	"o3":             200000,
+	"o4":             200000,
+	"claude":         200000,
+	"gemini-1.5-pro": 2097152,
+	"gemini":         1048576,
+	"llama3.1":       131072,
+	"llama3.2":       131072,
+	"llama3.3":       131072,
+	"llama3":         8192,
+	"mistral":        32768,
+	"qwen2.5":        32768,
+	"codellama":      16384,
+}
+
+var contextWindowsMu sync.RWMutex
+
+// defaultContextWindow is deliberately conservative for unknown (often local) models.
+const defaultContextWindow = 8192
+
+const (
+	// Room left for the system prompt, template text and the model's reply.
+	promptReserveTokens = 4096
+	// Even million-token models don't need (or want to pay for) more diff than this.
+	maxDiffBudgetTokens = 32000
+)
+
+// RegisterContextWindow sets the context size for models starting with
+// prefix, overriding any built-in entry for the same prefix.
+func RegisterContextWindow(prefix string, tokens int) {
+	if prefix == "" || tokens <= 0 {
+		return
+	}
+	contextWindowsMu.Lock()
+	defer contextWindowsMu.Unlock()
+	contextWindows[strings.ToLower(strings.TrimPrefix(prefix, "models/"))] = tokens
+}
+
+// ContextWindow returns the context size in tokens for a model, best effort by name.
+func ContextWindow(model string) int {
+	name := strings.ToLower(model)
+	// Ollama tags and Gemini resource names: "llama3:8b", "models/gemini-1.5-pro"
+	name = strings.TrimPrefix(name, "models/")
+	contextWindowsMu.RLock()
+	defer contextWindowsMu.RUnlock()
+	best, window := 0, defaultContextWindow
+	for prefix, size := range contextWindows {
+		if strings.HasPrefix(name, prefix) && len(prefix) > best {
+			best, window = len(prefix), size
+		}
+	}
+	return window
+}
+
+// DiffBudget returns how many tokens of a commit/PR prompt may be spent on the diff itself.
+func DiffBudget(model string) int {
+	budget := (ContextWindow(model) - promptReserveTokens) / 2
+	if budget > maxDiffBudgetTokens {
+		budget = maxDiffBudgetTokens
+	}
+	if budget < 1024 {
+		budget = 1024
+	}
+	return budget
+}
@@ -2,80 +2,84 @@ package provider
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
+
+	"github.com/eliau2005/ai-git/internal/config"
+	"github.com/eliau2005/ai-git/internal/prompt"
 )
 
 type OllamaProvider struct {
-	BaseURL      string
-	Model        string
-	SystemPrompt string
-	CommitPrompt string
+	Name           string // config entry name; empty means "ollama"
+	BaseURL        string
+	Model          string
+	EmbeddingModel string
+	SystemPrompt   string
+	CommitPrompt   string
+	Params         config.GenerationConfig
+	HTTPConfig
 }
 
 func (p *OllamaProvider) GetName() string {
+	if p.Name != "" {
+		return p.Name
+	}
 	return "ollama"
 }
 
-type ollamaGenerateRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
-	System string `json:"system,omitempty"`
-	Stream bool   `json:"stream"`
-}
-
-type ollamaGenerateResponse struct {
-	Response string `json:"response"`
-	Error    string `json:"error,omitempty"`
-}
-
-func (p *OllamaProvider) GenerateCommitMessage(diff string, context string) (string, error) {
-	// Truncate
-	if len(diff) > 15000 {
-		diff = diff[:15000] + "\n... [Diff truncated] ..."
-	}
-
-	url := p.BaseURL
-	if url == "" {
-		url = "http://localhost:11434/api/generate"
-	} else {
-		url = url + "/api/generate"
+func (p *OllamaProvider) endpoint(path string) string {
+	if p.BaseURL == "" {
+		return "http://localhost:11434" + path
 	}
+	return strings.TrimSuffix(p.BaseURL, "/") + path
+}
 
+func (p *OllamaProvider) GenerateCommitMessage(ctx context.Context, diff string, contextStr string) (string, error) {
 	systemPrompt := p.SystemPrompt
 	if systemPrompt == "" {
-		systemPrompt = "You are an expert developer. Generate a raw git commit message. Output ONLY the message. Structure: a short title, then a blank line, then a description. No conversational filler, no quotes, no backticks."
+		systemPrompt = prompt.DefaultSystem
 	}
 
-	commitPromptTemplate := p.CommitPrompt
-	if commitPromptTemplate == "" {
-		commitPromptTemplate = "Generate a raw git commit message for the changes below. Output ONLY the message. Structure: a short title, then a blank line, then a description. No conversational filler, no quotes, no backticks.\n\nChanges:\n%s\n\n%s"
+	prompt, err := commitPrompt(ctx, p.CommitPrompt, diff, contextStr)
+	if err != nil {
+		return "", err
 	}
 
-	prompt := fmt.Sprintf(commitPromptTemplate, diff, context)
+	return p.Complete(ctx, systemPrompt, []Message{{Role: "user", Content: prompt}})
+}
 
-	reqBody := ollamaGenerateRequest{
-		Model:  p.Model,
-		Prompt: prompt,
-		System: systemPrompt,
-		Stream: false,
-	}
+func (p *OllamaProvider) Complete(ctx context.Context, system string, messages []Message) (string, error) {
+	return p.chat(ctx, p.newRequest(system, messages))
+}
+
+// CompleteJSON passes the schema as `format`, which constrains decoding
+// on Ollama 0.5 and later.
+func (p *OllamaProvider) CompleteJSON(ctx context.Context, system string, messages []Message, schema map[string]any) (string, error) {
+	reqBody := p.newRequest(system, messages)
+	reqBody.Format = schema
+	return p.chat(ctx, reqBody)
+}
+
+func (p *OllamaProvider) chat(ctx context.Context, reqBody ollamaChatRequest) (string, error) {
+	url := p.endpoint("/api/chat")
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		return "", err
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
+	client := p.httpClient()
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", err
@@ -83,15 +87,15 @@ func (p *OllamaProvider) GenerateCommitMessage(diff string, context string) (str
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		var errResp ollamaGenerateResponse
+		var errResp ollamaChatResponse
 		body, _ := io.ReadAll(resp.Body)
 		if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
-			return "", fmt.Errorf("Ollama API error: %s", errResp.Error)
+			return "", apiError(resp, fmt.Errorf("Ollama API error: %s", errResp.Error))
 		}
-		return "", fmt.Errorf("Ollama API error: %s", string(body))
+		return "", apiError(resp, fmt.Errorf("Ollama API error: %s", string(body)))
 	}
 
-	var result ollamaGenerateResponse
+	var result ollamaChatResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return "", err
 	}
@@ -99,6 +103,18 @@ func (p *OllamaProvider) GenerateCommitMessage(diff string, context string) (str
 	if result.Error != "" {
 		return "", fmt.Errorf("Ollama error: %s", result.Error)
 	}
+	p.reportUsage("ollama", p.GetName(), p.Model, result.PromptEvalCount, result.EvalCount)
+
+	return result.Message.Content, nil
+}
 
-	return result.Response, nil
+func ollamaMessages(system string, messages []Message) []ollamaChatMessage {
+	var out []ollamaChatMessage
+	if system != "" {
+		out = append(out, ollamaChatMessage{Role: "system", Content: system})
+	}
+	for _, m := range messages {
+		out = append(out, ollamaChatMessage{Role: m.Role, Content: m.Content})
+	}
+	return out
 }
@@ -0,0 +1,92 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+type ollamaChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ollamaChatRequest struct {
+	Model    string              `json:"model"`
+	Messages []ollamaChatMessage `json:"messages"`
+	Stream   bool                `json:"stream"`
+	Format   any                 `json:"format,omitempty"` // "json" or a JSON schema
+	Options  map[string]any      `json:"options,omitempty"`
+}
+
+type ollamaChatResponse struct {
+	Message         ollamaChatMessage `json:"message"`
+	Done            bool              `json:"done"`
+	PromptEvalCount int               `json:"prompt_eval_count"` // token counts, sent with the final message
+	EvalCount       int               `json:"eval_count"`
+	Error           string            `json:"error,omitempty"`
+}
+
+func (p *OllamaProvider) AskChatStream(ctx context.Context, messages []Message, contextStr string, onChunk func(string)) error {
+	url := p.endpoint("/api/chat")
+
+	reqBody := p.newRequest(p.SystemPrompt, chatMessages(messages, contextStr))
+	reqBody.Stream = true
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	client := p.streamingClient()
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp ollamaChatResponse
+		body, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
+			return apiError(resp, fmt.Errorf("Ollama API error: %s", errResp.Error))
+		}
+		return apiError(resp, fmt.Errorf("Ollama API error: %s", string(body)))
+	}
+
+	// Ollama streams newline-delimited JSON objects rather than SSE
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var chunkResp ollamaChatResponse
+		if err := json.Unmarshal(line, &chunkResp); err != nil {
+			continue
+		}
+		if chunkResp.Error != "" {
+			return fmt.Errorf("Ollama error: %s", chunkResp.Error)
+		}
+		if chunkResp.Message.Content != "" {
+			onChunk(chunkResp.Message.Content)
+		}
+		if chunkResp.Done {
+			p.reportUsage("ollama", p.GetName(), p.Model, chunkResp.PromptEvalCount, chunkResp.EvalCount)
+			break
+		}
+	}
+
+	return scanner.Err()
+}
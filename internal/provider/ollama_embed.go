@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+type ollamaEmbedRequest struct {
+	Model string `json:"model"`
+	Input string `json:"input"`
+}
+
+type ollamaEmbedResponse struct {
+	Embeddings      [][]float32 `json:"embeddings"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+	Error           string      `json:"error,omitempty"`
+}
+
+func (p *OllamaProvider) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	model := p.EmbeddingModel
+	if model == "" {
+		model = "nomic-embed-text"
+	}
+	url := p.endpoint("/api/embed")
+
+	reqBody := ollamaEmbedRequest{
+		Model: model,
+		Input: text,
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	client := p.httpClient()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp ollamaEmbedResponse
+		body, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
+			return nil, apiError(resp, fmt.Errorf("Ollama Embed API error: %s", errResp.Error))
+		}
+		return nil, apiError(resp, fmt.Errorf("Ollama Embed API error: %s", string(body)))
+	}
+
+	var result ollamaEmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	if result.Error != "" {
+		return nil, fmt.Errorf("Ollama error: %s", result.Error)
+	}
+	p.reportUsage("ollama", p.GetName(), model, result.PromptEvalCount, 0)
+
+	if len(result.Embeddings) == 0 {
+		return nil, fmt.Errorf("no embedding returned from Ollama")
+	}
+
+	return result.Embeddings[0], nil
+}
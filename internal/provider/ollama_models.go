@@ -0,0 +1,28 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+)
+
+// ListModels returns the models pulled into the local Ollama server.
+func (p *OllamaProvider) ListModels(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequest("GET", p.endpoint("/api/tags"), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := getJSON(ctx, p.httpClient(), req, "Ollama", &result); err != nil {
+		return nil, err
+	}
+	models := make([]string, 0, len(result.Models))
+	for _, m := range result.Models {
+		models = append(models, m.Name)
+	}
+	return models, nil
+}
@@ -2,216 +2,248 @@ package provider
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/eliau2005/ai-git/internal/config"
+	"github.com/eliau2005/ai-git/internal/prompt"
 )
 
+// OpenAIProvider talks to the OpenAI API or any server that speaks it
+// (vLLM, LM Studio, OpenRouter, Azure gateways) via BaseURL.
 type OpenAIProvider struct {
-
-	APIKey       string
-
-	Model        string
-
-	SystemPrompt string
-
-	CommitPrompt string
-
+	Name           string // config entry name; empty means "openai"
+	APIKey         string
+	BaseURL        string
+	Headers        map[string]string
+	APIVersion     string
+	Model          string
+	EmbeddingModel string
+	SystemPrompt   string
+	CommitPrompt   string
+	Params         config.GenerationConfig
+	HTTPConfig
 }
 
-
-
 func (p *OpenAIProvider) GetName() string {
-
+	if p.Name != "" {
+		return p.Name
+	}
 	return "openai"
-
 }
 
+func (p *OpenAIProvider) endpoint(path string) string {
+	base := p.BaseURL
+	if base == "" {
+		base = "https://api.openai.com/v1"
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		// Let the request report the bad URL
+		return strings.TrimSuffix(base, "/") + path
+	}
+	// Gateway URLs often carry a query string of their own
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	if p.APIVersion != "" {
+		q := u.Query()
+		q.Set("api-version", p.APIVersion)
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
 
+// official reports whether BaseURL points at the OpenAI API itself, which
+// supports features compatible servers often reject. An explicit
+// https://api.openai.com/v1 counts as well as an empty BaseURL.
+func (p *OpenAIProvider) official() bool {
+	if p.BaseURL == "" {
+		return true
+	}
+	u, err := url.Parse(p.BaseURL)
+	return err == nil && strings.EqualFold(u.Hostname(), "api.openai.com")
+}
 
-type openAIChatCompletionRequest struct {
-
-	Model    string `json:"model"`
-
-	Messages []struct {
+func (p *OpenAIProvider) setHeaders(req *http.Request) {
+	req.Header.Set("Content-Type", "application/json")
+	// Local servers often run without auth; Azure wants an api-key header instead (set via headers)
+	if p.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+	for k, v := range p.Headers {
+		req.Header.Set(k, v)
+	}
+}
 
-		Role    string `json:"role"`
+type openAIChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
 
-		Content string `json:"content"`
+type openAIChatCompletionRequest struct {
+	Model          string                `json:"model"`
+	Stream         bool                  `json:"stream,omitempty"`
+	StreamOptions  *openAIStreamOptions  `json:"stream_options,omitempty"`
+	N              int                   `json:"n,omitempty"`
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+	Messages       []openAIChatMessage   `json:"messages"`
+
+	Temperature         *float64 `json:"temperature,omitempty"`
+	TopP                *float64 `json:"top_p,omitempty"`
+	MaxTokens           int      `json:"max_tokens,omitempty"`
+	MaxCompletionTokens int      `json:"max_completion_tokens,omitempty"`
+	Stop                []string `json:"stop,omitempty"`
+}
 
-	} `json:"messages"`
+type openAIResponseFormat struct {
+	Type       string            `json:"type"` // "json_object" or "json_schema"
+	JSONSchema *openAIJSONSchema `json:"json_schema,omitempty"`
+}
 
+type openAIJSONSchema struct {
+	Name   string         `json:"name"`
+	Strict bool           `json:"strict"`
+	Schema map[string]any `json:"schema"`
 }
 
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
 
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
 
 type openAIChatCompletionResponse struct {
-
 	Choices []struct {
-
 		Message struct {
-
 			Content string `json:"content"`
-
 		} `json:"message"`
-
 	} `json:"choices"`
-
+	Usage openAIUsage `json:"usage"`
 	Error struct {
-
-		Message string `json:"message"`
-
-		Type    string `json:"type"`
-
+		Message string      `json:"message"`
+		Type    string      `json:"type"`
 		Code    interface{} `json:"code"` // Can be string or null
-
 	} `json:"error,omitempty"`
-
 }
 
-
-
-func (p *OpenAIProvider) GenerateCommitMessage(diff string, context string) (string, error) {
-
-	// Truncate diff if too large
-
-	if len(diff) > 15000 {
-
-		diff = diff[:15000] + "\n... [Diff truncated] ..."
-
-	}
-
-
-
-	url := "https://api.openai.com/v1/chat/completions"
-
-
-
-	systemPrompt := p.SystemPrompt
-
-	if systemPrompt == "" {
-
-		systemPrompt = "You are an expert developer. Generate a raw git commit message. Output ONLY the message. Structure: a short title, then a blank line, then a description. No conversational filler, no quotes, no backticks."
-
+func (p *OpenAIProvider) GenerateCommitMessage(ctx context.Context, diff string, contextStr string) (string, error) {
+	userPrompt, err := commitPrompt(ctx, p.CommitPrompt, diff, contextStr)
+	if err != nil {
+		return "", err
 	}
+	return p.Complete(ctx, p.commitSystemPrompt(), []Message{{Role: "user", Content: userPrompt}})
+}
 
-	
-
-	commitPromptTemplate := p.CommitPrompt
-
-	if commitPromptTemplate == "" {
-
-		commitPromptTemplate = "Generate a git commit message for these changes:\n\n%s\n\n%s"
-
+// GenerateCommitMessages asks for n alternatives in a single request via the `n` parameter.
+func (p *OpenAIProvider) GenerateCommitMessages(ctx context.Context, diff string, contextStr string, n int) ([]string, error) {
+	userPrompt, err := commitPrompt(ctx, p.CommitPrompt, diff, contextStr)
+	if err != nil {
+		return nil, err
 	}
+	return p.completeN(ctx, p.commitSystemPrompt(), []Message{{Role: "user", Content: userPrompt}}, n, nil)
+}
 
-
-
-	userPrompt := fmt.Sprintf(commitPromptTemplate, diff, context)
-
-
-
-	reqBody := openAIChatCompletionRequest{
-
-		Model: p.Model,
-
-		Messages: []struct {
-
-			Role    string `json:"role"`
-
-			Content string `json:"content"`
-
-		}{
-
-			{Role: "system", Content: systemPrompt},
-
-			{Role: "user", Content: userPrompt},
-
-		},
-
+func (p *OpenAIProvider) commitSystemPrompt() string {
+	if p.SystemPrompt == "" {
+		return prompt.DefaultSystem
 	}
+	return p.SystemPrompt
+}
 
-
-
-	jsonData, err := json.Marshal(reqBody)
-
+func (p *OpenAIProvider) Complete(ctx context.Context, system string, messages []Message) (string, error) {
+	choices, err := p.completeN(ctx, system, messages, 1, nil)
 	if err != nil {
-
 		return "", err
-
 	}
+	return choices[0], nil
+}
 
-
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-
+// CompleteJSON uses strict structured outputs on the OpenAI API. Compatible
+// servers get plain JSON mode, which more of them understand.
+func (p *OpenAIProvider) CompleteJSON(ctx context.Context, system string, messages []Message, schema map[string]any) (string, error) {
+	format := &openAIResponseFormat{Type: "json_object"}
+	if p.official() {
+		format = &openAIResponseFormat{
+			Type:       "json_schema",
+			JSONSchema: &openAIJSONSchema{Name: "response", Strict: true, Schema: schema},
+		}
+	}
+	choices, err := p.completeN(ctx, system, messages, 1, format)
 	if err != nil {
-
 		return "", err
-
 	}
+	return choices[0], nil
+}
 
+func (p *OpenAIProvider) completeN(ctx context.Context, system string, messages []Message, n int, format *openAIResponseFormat) ([]string, error) {
+	url := p.endpoint("/chat/completions")
 
+	reqBody := p.newRequest(system, messages)
+	reqBody.ResponseFormat = format
+	if n > 1 {
+		reqBody.N = n
+	}
 
-	req.Header.Set("Content-Type", "application/json")
-
-	req.Header.Set("Authorization", "Bearer "+p.APIKey)
-
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
 
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
 
-	client := &http.Client{}
+	p.setHeaders(req)
 
+	client := p.httpClient()
 	resp, err := client.Do(req)
-
 	if err != nil {
-
-		return "", err
-
+		return nil, err
 	}
-
 	defer resp.Body.Close()
 
-
-
 	if resp.StatusCode != http.StatusOK {
-
 		var errResp openAIChatCompletionResponse
-
 		body, _ := io.ReadAll(resp.Body)
-
 		if json.Unmarshal(body, &errResp) == nil && errResp.Error.Message != "" {
-
-			return "", fmt.Errorf("OpenAI API error: %s (Type: %s)", errResp.Error.Message, errResp.Error.Type)
-
+			return nil, apiError(resp, fmt.Errorf("OpenAI API error: %s (Type: %s)", errResp.Error.Message, errResp.Error.Type))
 		}
-
-		return "", fmt.Errorf("OpenAI API error: %s", string(body))
-
+		return nil, apiError(resp, fmt.Errorf("OpenAI API error: %s", string(body)))
 	}
 
-
-
 	var result openAIChatCompletionResponse
-
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-
-		return "", err
-
+		return nil, err
 	}
 
+	p.reportUsage("openai", p.GetName(), p.Model, result.Usage.PromptTokens, result.Usage.CompletionTokens)
 
-
-	if len(result.Choices) > 0 {
-
-		return result.Choices[0].Message.Content, nil
-
+	if len(result.Choices) == 0 {
+		return nil, fmt.Errorf("no response from OpenAI")
 	}
 
-
-
-	return "", fmt.Errorf("no response from OpenAI")
-
+	choices := make([]string, 0, len(result.Choices))
+	for _, c := range result.Choices {
+		choices = append(choices, c.Message.Content)
+	}
+	return choices, nil
 }
 
-
+func openAIMessages(system string, messages []Message) []openAIChatMessage {
+	var out []openAIChatMessage
+	if system != "" {
+		out = append(out, openAIChatMessage{Role: "system", Content: system})
+	}
+	for _, m := range messages {
+		out = append(out, openAIChatMessage{Role: m.Role, Content: m.Content})
+	}
+	return out
+}
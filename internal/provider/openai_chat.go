@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type openAIChatCompletionChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"` // only on the final chunk, with include_usage
+}
+
+func (p *OpenAIProvider) AskChatStream(ctx context.Context, messages []Message, contextStr string, onChunk func(string)) error {
+	url := p.endpoint("/chat/completions")
+
+	reqBody := p.newRequest(p.SystemPrompt, chatMessages(messages, contextStr))
+	reqBody.Stream = true
+	// Compatible servers may reject stream_options, so only ask the official API for usage
+	if p.official() {
+		reqBody.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+
+	p.setHeaders(req)
+	req.Header.Set("Accept", "text/event-stream")
+
+	client := p.streamingClient()
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp openAIChatCompletionResponse
+		body, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(body, &errResp) == nil && errResp.Error.Message != "" {
+			return apiError(resp, fmt.Errorf("OpenAI API error: %s", errResp.Error.Message))
+		}
+		return apiError(resp, fmt.Errorf("OpenAI API error: %s", string(body)))
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "data: ") {
+			data := strings.TrimPrefix(line, "data: ")
+			if data == "[DONE]" {
+				break
+			}
+			var chunkResp openAIChatCompletionChunk
+			if err := json.Unmarshal([]byte(data), &chunkResp); err == nil {
+				if len(chunkResp.Choices) > 0 && chunkResp.Choices[0].Delta.Content != "" {
+					onChunk(chunkResp.Choices[0].Delta.Content)
+				}
+				if chunkResp.Usage != nil {
+					p.reportUsage("openai", p.GetName(), p.Model, chunkResp.Usage.PromptTokens, chunkResp.Usage.CompletionTokens)
+				}
+			}
+		}
+	}
+
+	return scanner.Err()
+}
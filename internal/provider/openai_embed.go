@@ -0,0 +1,80 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+type openAIEmbedRequest struct {
+	Model string `json:"model"`
+	Input string `json:"input"`
+}
+
+type openAIEmbedResponse struct {
+	Data []struct {
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Usage openAIUsage `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error,omitempty"`
+}
+
+func (p *OpenAIProvider) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	model := p.EmbeddingModel
+	if model == "" {
+		model = "text-embedding-3-small"
+	}
+	url := p.endpoint("/embeddings")
+
+	reqBody := openAIEmbedRequest{
+		Model: model,
+		Input: text,
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	p.setHeaders(req)
+
+	client := p.httpClient()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp openAIEmbedResponse
+		body, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(body, &errResp) == nil && errResp.Error != nil {
+			return nil, apiError(resp, fmt.Errorf("OpenAI Embed API error: %s (Type: %s)", errResp.Error.Message, errResp.Error.Type))
+		}
+		return nil, apiError(resp, fmt.Errorf("OpenAI Embed API error: %s", string(body)))
+	}
+
+	var result openAIEmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	p.reportUsage("openai", p.GetName(), model, result.Usage.PromptTokens, 0)
+
+	if len(result.Data) == 0 {
+		return nil, fmt.Errorf("no embedding returned from OpenAI")
+	}
+
+	return result.Data[0].Embedding, nil
+}
@@ -0,0 +1,28 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+)
+
+func (p *OpenAIProvider) ListModels(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequest("GET", p.endpoint("/models"), nil)
+	if err != nil {
+		return nil, err
+	}
+	p.setHeaders(req)
+
+	var result struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := getJSON(ctx, p.httpClient(), req, "OpenAI", &result); err != nil {
+		return nil, err
+	}
+	models := make([]string, 0, len(result.Data))
+	for _, m := range result.Data {
+		models = append(models, m.ID)
+	}
+	return models, nil
+}
@@ -0,0 +1,46 @@
+package provider
+
+import "testing"
+
+func TestOpenAIEndpoint(t *testing.T) {
+	tests := []struct {
+		baseURL, apiVersion string
+		want                string
+		official            bool
+	}{
+		{"", "", "https://api.openai.com/v1/chat/completions", true},
+		{"https://api.openai.com/v1/", "", "https://api.openai.com/v1/chat/completions", true},
+		{"https://API.OpenAI.com:443/v1", "", "https://API.OpenAI.com:443/v1/chat/completions", true},
+		{"http://localhost:1234/v1", "", "http://localhost:1234/v1/chat/completions", false},
+		{"https://gw.example.com/openai/deployments/gpt?team=a", "2024-06-01", "https://gw.example.com/openai/deployments/gpt/chat/completions?api-version=2024-06-01&team=a", false},
+		{"https://api.openai.com.example.com/v1", "", "https://api.openai.com.example.com/v1/chat/completions", false},
+	}
+	for _, tt := range tests {
+		p := &OpenAIProvider{BaseURL: tt.baseURL, APIVersion: tt.apiVersion}
+		if got := p.endpoint("/chat/completions"); got != tt.want {
+			t.Errorf("endpoint with %q = %q, want %q", tt.baseURL, got, tt.want)
+		}
+		if got := p.official(); got != tt.official {
+			t.Errorf("official() with %q = %v, want %v", tt.baseURL, got, tt.official)
+		}
+	}
+}
+
+func TestOpenAIMaxTokensField(t *testing.T) {
+	tests := []struct {
+		baseURL    string
+		completion bool // max_completion_tokens rather than max_tokens
+	}{
+		{"", true},
+		{"https://api.openai.com/v1", true},
+		{"http://localhost:11434/v1", false},
+	}
+	for _, tt := range tests {
+		p := &OpenAIProvider{BaseURL: tt.baseURL}
+		p.Params.MaxTokens = 100
+		req := p.newRequest("", nil)
+		if got := req.MaxCompletionTokens == 100 && req.MaxTokens == 0; got != tt.completion {
+			t.Errorf("%q: max_completion_tokens = %d, max_tokens = %d", tt.baseURL, req.MaxCompletionTokens, req.MaxTokens)
+		}
+	}
+}
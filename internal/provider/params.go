@@ -0,0 +1,92 @@
+package provider
+
+import "github.com/eliau2005/ai-git/internal/config"
+
+// defaultAnthropicMaxTokens is used when max_tokens isn't configured, as
+// the Messages API requires one.
+const defaultAnthropicMaxTokens = 4096
+
+func (p *OpenAIProvider) newRequest(system string, messages []Message) openAIChatCompletionRequest {
+	g := p.Params
+	req := openAIChatCompletionRequest{
+		Model:       p.Model,
+		Messages:    openAIMessages(system, messages),
+		Temperature: g.Temperature,
+		TopP:        g.TopP,
+		Stop:        g.Stop,
+	}
+	// The official API has replaced max_tokens, which compatible servers still expect
+	if p.official() {
+		req.MaxCompletionTokens = g.MaxTokens
+	} else {
+		req.MaxTokens = g.MaxTokens
+	}
+	return req
+}
+
+func (p *AnthropicProvider) newRequest(system string, messages []Message) anthropicMessagesRequest {
+	g := p.Params
+	req := anthropicMessagesRequest{
+		Model:         p.Model,
+		System:        system,
+		MaxTokens:     g.MaxTokens,
+		Messages:      anthropicMessages(messages),
+		Temperature:   g.Temperature,
+		TopP:          g.TopP,
+		StopSequences: g.Stop,
+	}
+	if req.MaxTokens == 0 {
+		req.MaxTokens = defaultAnthropicMaxTokens
+	}
+	return req
+}
+
+func (p *GeminiProvider) newRequest(system string, messages []Message) geminiGenerateContentRequest {
+	g := p.Params
+	req := geminiRequest(system, messages)
+	if g.Temperature != nil || g.TopP != nil || g.MaxTokens != 0 || len(g.Stop) > 0 {
+		req.GenerationConfig = &geminiGenerationConfig{
+			Temperature:     g.Temperature,
+			TopP:            g.TopP,
+			MaxOutputTokens: g.MaxTokens,
+			StopSequences:   g.Stop,
+		}
+	}
+	return req
+}
+
+func (p *OllamaProvider) newRequest(system string, messages []Message) ollamaChatRequest {
+	return ollamaChatRequest{
+		Model:    p.Model,
+		Messages: ollamaMessages(system, messages),
+		Options:  ollamaOptions(p.Params),
+	}
+}
+
+// ollamaOptions maps the parameters onto Ollama's names; explicit
+// options win over the mapped ones.
+func ollamaOptions(g config.GenerationConfig) map[string]any {
+	opts := make(map[string]any)
+	if g.Temperature != nil {
+		opts["temperature"] = *g.Temperature
+	}
+	if g.TopP != nil {
+		opts["top_p"] = *g.TopP
+	}
+	if g.MaxTokens != 0 {
+		opts["num_predict"] = g.MaxTokens
+	}
+	if len(g.Stop) > 0 {
+		opts["stop"] = g.Stop
+	}
+	if g.ContextWindow != 0 {
+		opts["num_ctx"] = g.ContextWindow
+	}
+	for k, v := range g.Options {
+		opts[k] = v
+	}
+	if len(opts) == 0 {
+		return nil
+	}
+	return opts
+}
@@ -1,64 +1,205 @@
 package provider
 
 import (
+	"context"
+	"net/http"
+	"os"
+
 	"github.com/eliau2005/ai-git/internal/config"
 )
 
 type Provider interface {
-	GenerateCommitMessage(diff string, context string) (string, error)
+	GenerateCommitMessage(ctx context.Context, diff string, contextStr string) (string, error)
 	GetName() string
 }
 
+// Message is a single turn in a conversation sent to a Completer.
+// Role is "user" or "assistant"; providers map it to their own vocabulary.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Completer is the low-level primitive every provider implements:
+// a system prompt plus a conversation in, the model's text reply out.
+type Completer interface {
+	Complete(ctx context.Context, system string, messages []Message) (string, error)
+}
+
+// Chatter streams the assistant's reply to a conversation. The last
+// message is the user's new question; contextStr, e.g. retrieved code, is
+// attached to it.
 type Chatter interface {
-	AskChatStream(prompt string, context string, onChunk func(string)) error
+	AskChatStream(ctx context.Context, messages []Message, contextStr string, onChunk func(string)) error
 }
 
 type Embedder interface {
-	GenerateEmbedding(text string) ([]float32, error)
+	GenerateEmbedding(ctx context.Context, text string) ([]float32, error)
 }
 
 type ConflictResolver interface {
-	ResolveConflict(fileContent string) (string, error)
+	ResolveConflict(ctx context.Context, fileContent string) (string, error)
 }
 
 type CodeRefactorer interface {
-	RefactorCode(prompt string, fileContent string) (string, error)
+	RefactorCode(ctx context.Context, prompt string, fileContent string) (string, error)
 }
 
 type ProviderFactory struct {
 }
 
 func (f *ProviderFactory) GetProvider(name string, pCfg config.ProviderConfig, model string, systemPrompt string, commitPromptTemplate string) Provider {
-	switch name {
+	// Secrets are resolved here, at use time, so references stay in the
+	// config file; a failure surfaces on the provider's first request.
+	pCfg, secretErr := pCfg.WithSecrets(name)
+	httpCfg := func() HTTPConfig {
+		c := httpConfig(name, pCfg)
+		if secretErr != nil {
+			c.Transport = errorTransport{secretErr}
+		}
+		return c
+	}
+
+	switch pCfg.Kind(name) {
 	case "openai":
 		return &OpenAIProvider{
-			APIKey:       pCfg.APIKey,
-			Model:        model,
-			SystemPrompt: systemPrompt,
-			CommitPrompt: commitPromptTemplate,
+			Name:           name,
+			APIKey:         pCfg.APIKey,
+			BaseURL:        pCfg.BaseURL,
+			Headers:        pCfg.Headers,
+			APIVersion:     pCfg.APIVersion,
+			Model:          model,
+			EmbeddingModel: pCfg.EmbeddingModel,
+			SystemPrompt:   systemPrompt,
+			CommitPrompt:   commitPromptTemplate,
+			Params:         pCfg.Generation,
+			HTTPConfig:     httpCfg(),
 		}
 	case "gemini":
 		return &GeminiProvider{
-			APIKey:       pCfg.APIKey,
-			Model:        model,
-			SystemPrompt: systemPrompt,
-			CommitPrompt: commitPromptTemplate,
+			Name:           name,
+			APIKey:         pCfg.APIKey,
+			Model:          model,
+			EmbeddingModel: pCfg.EmbeddingModel,
+			SystemPrompt:   systemPrompt,
+			CommitPrompt:   commitPromptTemplate,
+			Params:         pCfg.Generation,
+			HTTPConfig:     httpCfg(),
 		}
 	case "ollama":
 		return &OllamaProvider{
-			BaseURL:      pCfg.BaseURL,
-			Model:        model,
-			SystemPrompt: systemPrompt,
-			CommitPrompt: commitPromptTemplate,
+			Name:           name,
+			BaseURL:        pCfg.BaseURL,
+			Model:          model,
+			EmbeddingModel: pCfg.EmbeddingModel,
+			SystemPrompt:   systemPrompt,
+			CommitPrompt:   commitPromptTemplate,
+			Params:         pCfg.Generation,
+			HTTPConfig:     httpCfg(),
+		}
+	case "exec":
+		p := &ExecProvider{
+			Name:           name,
+			Command:        pCfg.Command,
+			Args:           pCfg.Args,
+			Model:          model,
+			EmbeddingModel: pCfg.EmbeddingModel,
+			SystemPrompt:   systemPrompt,
+			CommitPrompt:   commitPromptTemplate,
+			Params:         pCfg.Generation,
+		}
+		// Discover capabilities now; StartError reports a failure
+		p.Start()
+		return p
+	case "fake":
+		return &FakeProvider{
+			Name:      name,
+			Responses: pCfg.Responses,
 		}
+	case "replay":
+		// Rebuild the provider the cassette was recorded with; its HTTP
+		// client then replays instead of going to the network.
+		cassette, err := LoadCassette(pCfg.Cassette)
+		if err != nil || cassette.Kind() == "" || cassette.Kind() == "replay" {
+			return nil
+		}
+		inner := pCfg
+		inner.Type = cassette.Kind()
+		return f.GetProvider(name, inner, model, systemPrompt, commitPromptTemplate)
 	case "anthropic":
 		return &AnthropicProvider{
+			Name:         name,
 			APIKey:       pCfg.APIKey,
 			Model:        model,
 			SystemPrompt: systemPrompt,
 			CommitPrompt: commitPromptTemplate,
+			Params:       pCfg.Generation,
+			HTTPConfig:   httpCfg(),
 		}
 	default:
 		return nil
 	}
 }
+
+func httpConfig(name string, pCfg config.ProviderConfig) HTTPConfig {
+	c := HTTPConfig{
+		Timeout:    pCfg.Timeout,
+		MaxRetries: pCfg.MaxRetries,
+	}
+	switch {
+	case pCfg.Cassette != "":
+		cassette, err := LoadCassette(pCfg.Cassette)
+		if err != nil {
+			c.Transport = errorTransport{err}
+		} else {
+			c.Transport = newReplayTransport(cassette)
+		}
+	case os.Getenv(RecordEnv) != "":
+		secrets := []string{pCfg.APIKey}
+		for _, v := range pCfg.Headers {
+			secrets = append(secrets, v)
+		}
+		c.Transport = newRecordingTransport(http.DefaultTransport, os.Getenv(RecordEnv), pCfg.Kind(name), secrets...)
+	}
+	return c
+}
+
+// errorTransport fails every request, for surfacing setup errors at call time.
+type errorTransport struct {
+	err error
+}
+
+func (t errorTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, t.err
+}
+
+// StartError reports why p, as built by GetProvider, can't serve any
+// request, such as a plugin that failed to start or to announce its
+// capabilities. It is nil for providers without a handshake.
+func StartError(p Provider) error {
+	if s, ok := p.(interface{ Start() error }); ok {
+		return s.Start()
+	}
+	return nil
+}
+
+// WithFallbacks chains primary, built from the entry primaryName, with the
+// named fallback providers, each using its own default model. Unknown
+// names and the primary's entry are skipped; without any usable fallback,
+// primary is returned unchanged.
+func (f *ProviderFactory) WithFallbacks(primary Provider, primaryName string, cfg *config.Config, names []string) Provider {
+	chain := []Provider{primary}
+	for _, name := range names {
+		pCfg, ok := cfg.Providers[name]
+		if !ok || name == primaryName {
+			continue
+		}
+		if p := f.GetProvider(name, pCfg, pCfg.DefaultModel, cfg.SystemPrompt, cfg.CommitPromptTemplate); p != nil {
+			chain = append(chain, p)
+		}
+	}
+	if len(chain) == 1 {
+		return primary
+	}
+	return &FallbackProvider{Providers: chain}
+}
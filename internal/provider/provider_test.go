@@ -0,0 +1,54 @@
+package provider
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/eliau2005/ai-git/internal/config"
+)
+
+func TestGetProviderName(t *testing.T) {
+	f := &ProviderFactory{}
+	for _, kind := range []string{"openai", "anthropic", "gemini", "ollama", "exec", "fake"} {
+		p := f.GetProvider("work", config.ProviderConfig{Type: kind}, "m", "", "")
+		if p == nil {
+			t.Fatalf("%s: no provider", kind)
+		}
+		if got := p.GetName(); got != "work" {
+			t.Errorf("%s: GetName() = %q, want the entry name", kind, got)
+		}
+	}
+}
+
+func TestWithFallbacks(t *testing.T) {
+	cfg := &config.Config{Providers: map[string]config.ProviderConfig{
+		"local": {Type: "ollama", DefaultModel: "llama3"},
+		"gpu":   {Type: "ollama", DefaultModel: "qwen"},
+		"cloud": {Type: "openai", DefaultModel: "gpt-4o-mini"},
+	}}
+	f := &ProviderFactory{}
+	primary := f.GetProvider("local", cfg.Providers["local"], "llama3", "", "")
+
+	tests := []struct {
+		names []string
+		want  []string // the chain, primary first
+	}{
+		{nil, []string{"local"}},
+		{[]string{"local"}, []string{"local"}},
+		{[]string{"local", "gpu", "missing", "cloud"}, []string{"local", "gpu", "cloud"}},
+	}
+	for _, tt := range tests {
+		p := f.WithFallbacks(primary, "local", cfg, tt.names)
+		var got []string
+		if chain, ok := p.(*FallbackProvider); ok {
+			for _, member := range chain.Providers {
+				got = append(got, member.GetName())
+			}
+		} else {
+			got = []string{p.GetName()}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("fallbacks %q: chain = %q, want %q", tt.names, got, tt.want)
+		}
+	}
+}
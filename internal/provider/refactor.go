@@ -0,0 +1,39 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/eliau2005/ai-git/internal/prompt"
+)
+
+const refactorSystemPrompt = "You are an expert autonomous developer. Your goal is to refactor or modify the provided code according to the user's instructions. " +
+	"Return ONLY the raw new code for the file. Do not include markdown code blocks (like ```go). " +
+	"Do not explain your changes. Output exactly what should be written to the file so it can be saved directly."
+
+func refactorCode(ctx context.Context, c Completer, instruction string, fileContent string) (string, error) {
+	userPrompt, err := prompt.From(ctx).Render(prompt.Refactor, prompt.Data{Instruction: instruction, Content: fileContent})
+	if err != nil {
+		return "", err
+	}
+	text, err := c.Complete(ctx, refactorSystemPrompt, []Message{{Role: "user", Content: userPrompt}})
+	if err != nil {
+		return "", err
+	}
+	return stripCodeFence(text), nil
+}
+
+func (p *OpenAIProvider) RefactorCode(ctx context.Context, prompt string, fileContent string) (string, error) {
+	return refactorCode(ctx, p, prompt, fileContent)
+}
+
+func (p *GeminiProvider) RefactorCode(ctx context.Context, prompt string, fileContent string) (string, error) {
+	return refactorCode(ctx, p, prompt, fileContent)
+}
+
+func (p *AnthropicProvider) RefactorCode(ctx context.Context, prompt string, fileContent string) (string, error) {
+	return refactorCode(ctx, p, prompt, fileContent)
+}
+
+func (p *OllamaProvider) RefactorCode(ctx context.Context, prompt string, fileContent string) (string, error) {
+	return refactorCode(ctx, p, prompt, fileContent)
+}
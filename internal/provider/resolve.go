@@ -0,0 +1,40 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/eliau2005/ai-git/internal/prompt"
+)
+
+const resolveSystemPrompt = "You are an expert developer resolving git merge conflicts. " +
+	"I will provide you with a file containing standard git conflict markers (<<<<<<<, =======, >>>>>>>). " +
+	"Your job is to understand the context of the conflicting changes and output the fully merged file without any conflict markers. " +
+	"Return ONLY the raw code for the resolved file. Do not include markdown code blocks (like ```go). Output exactly what should be written to the file."
+
+func resolveConflict(ctx context.Context, c Completer, fileContent string) (string, error) {
+	userPrompt, err := prompt.From(ctx).Render(prompt.Resolve, prompt.Data{Content: fileContent})
+	if err != nil {
+		return "", err
+	}
+	text, err := c.Complete(ctx, resolveSystemPrompt, []Message{{Role: "user", Content: userPrompt}})
+	if err != nil {
+		return "", err
+	}
+	return stripCodeFence(text), nil
+}
+
+func (p *OpenAIProvider) ResolveConflict(ctx context.Context, fileContent string) (string, error) {
+	return resolveConflict(ctx, p, fileContent)
+}
+
+func (p *GeminiProvider) ResolveConflict(ctx context.Context, fileContent string) (string, error) {
+	return resolveConflict(ctx, p, fileContent)
+}
+
+func (p *AnthropicProvider) ResolveConflict(ctx context.Context, fileContent string) (string, error) {
+	return resolveConflict(ctx, p, fileContent)
+}
+
+func (p *OllamaProvider) ResolveConflict(ctx context.Context, fileContent string) (string, error) {
+	return resolveConflict(ctx, p, fileContent)
+}
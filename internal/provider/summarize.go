@@ -0,0 +1,87 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/eliau2005/ai-git/internal/prompt"
+)
+
+const summarizeSystemPrompt = "You are an expert developer reviewing one part of a large change set."
+
+// summarizeConcurrency bounds parallel map calls so we don't trip rate limits ourselves.
+const summarizeConcurrency = 4
+
+// MapReduceCommitMessage handles diffs too large for one prompt: every group
+// is summarized on its own (the map step, run concurrently), then the
+// provider writes the final commit message or PR description from the
+// summaries plus the overall --stat (the reduce step), returning n
+// candidates. onProgress receives human-readable status lines and may be nil.
+func MapReduceCommitMessage(ctx context.Context, p Provider, groups []string, stat string, contextStr string, n int, onProgress func(string)) ([]string, error) {
+	c, ok := p.(Completer)
+	if !ok {
+		return nil, fmt.Errorf("provider %s does not support multi-pass summarization", p.GetName())
+	}
+	progress := func(s string) {
+		if onProgress != nil {
+			onProgress(s)
+		}
+	}
+
+	summaries := make([]string, len(groups))
+	errs := make([]error, len(groups))
+	sem := make(chan struct{}, summarizeConcurrency)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	done := 0
+
+	prompts := prompt.From(ctx)
+	requests := make([]string, len(groups))
+	for i, group := range groups {
+		var err error
+		if requests[i], err = prompts.Render(prompt.Summarize, prompt.Data{Diff: group}); err != nil {
+			return nil, err
+		}
+	}
+
+	progress(fmt.Sprintf("Summarizing changes (0/%d)...", len(groups)))
+	for i, request := range requests {
+		wg.Add(1)
+		go func(i int, request string) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+
+			summaries[i], errs[i] = c.Complete(ctx, summarizeSystemPrompt, []Message{{Role: "user", Content: request}})
+
+			mu.Lock()
+			done++
+			progress(fmt.Sprintf("Summarizing changes (%d/%d)...", done, len(groups)))
+			mu.Unlock()
+		}(i, request)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Changed files:\n" + stat + "\n")
+	sb.WriteString("The full diff was too large to include, so here are summaries of each part of it:\n\n")
+	for i, s := range summaries {
+		sb.WriteString(fmt.Sprintf("### Part %d of %d\n%s\n\n", i+1, len(summaries), strings.TrimSpace(s)))
+	}
+
+	progress("Writing final message...")
+	return GenerateCandidates(ctx, p, sb.String(), contextStr, n)
+}
@@ -0,0 +1,39 @@
+package provider
+
+import "sync"
+
+// Usage is the token accounting of one API call, as reported by the provider.
+type Usage struct {
+	Kind         string // provider type, e.g. "ollama"
+	Provider     string // config entry name
+	Model        string
+	InputTokens  int
+	OutputTokens int
+}
+
+var (
+	usageMu   sync.Mutex
+	usageHook func(Usage)
+)
+
+// SetUsageHook registers fn to receive the usage of every API call made
+// by any provider. Calls may arrive concurrently.
+func SetUsageHook(fn func(Usage)) {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	usageHook = fn
+}
+
+// reportUsage passes a call's usage to the hook. Calls replayed from a
+// cassette cost nothing and are left out.
+func (c HTTPConfig) reportUsage(kind string, provider string, model string, input int, output int) {
+	if _, replayed := c.Transport.(*replayTransport); replayed || (input == 0 && output == 0) {
+		return
+	}
+	usageMu.Lock()
+	fn := usageHook
+	usageMu.Unlock()
+	if fn != nil {
+		fn(Usage{Kind: kind, Provider: provider, Model: model, InputTokens: input, OutputTokens: output})
+	}
+}
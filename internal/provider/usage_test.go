@@ -0,0 +1,37 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eliau2005/ai-git/internal/config"
+)
+
+func TestReportUsageEntry(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"message":{"role":"assistant","content":"ok"},"prompt_eval_count":7,"eval_count":3}`))
+	}))
+	defer srv.Close()
+
+	var got []Usage
+	SetUsageHook(func(u Usage) { got = append(got, u) })
+	defer SetUsageHook(nil)
+
+	factory := &ProviderFactory{}
+	for _, name := range []string{"local", "gpu-box"} {
+		p := factory.GetProvider(name, config.ProviderConfig{Type: "ollama", BaseURL: srv.URL}, "llama3", "", "")
+		if _, err := p.(Completer).Complete(context.Background(), "", []Message{{Role: "user", Content: "hi"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []Usage{
+		{Kind: "ollama", Provider: "local", Model: "llama3", InputTokens: 7, OutputTokens: 3},
+		{Kind: "ollama", Provider: "gpu-box", Model: "llama3", InputTokens: 7, OutputTokens: 3},
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("usage = %+v, want %+v", got, want)
+	}
+}
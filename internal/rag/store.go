@@ -16,6 +16,9 @@ type Chunk struct {
 }
 
 type Store struct {
+	// Model is the provider and embedding model that embedded the chunks;
+	// queries embedded any other way can't be compared with them.
+	Model  string  `json:"model,omitempty"`
 	Chunks []Chunk `json:"chunks"`
 }
 
@@ -0,0 +1,194 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eliau2005/ai-git/internal/config"
+)
+
+// Record is one API call in the usage ledger.
+type Record struct {
+	Time         time.Time `json:"time"`
+	Kind         string    `json:"kind,omitempty"`
+	Provider     string    `json:"provider"`
+	Model        string    `json:"model"`
+	Command      string    `json:"command"`
+	InputTokens  int       `json:"input_tokens"`
+	OutputTokens int       `json:"output_tokens"`
+}
+
+var ledgerMu sync.Mutex
+
+func GetLedgerPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "ai-git", "usage.jsonl"), nil
+}
+
+// Append adds rec to the ledger, one JSON object per line.
+func Append(rec Record) error {
+	path, err := GetLedgerPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	ledgerMu.Lock()
+	defer ledgerMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// Load returns the ledger entries recorded at or after since.
+func Load(since time.Time) ([]Record, error) {
+	path, err := GetLedgerPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec Record
+		// Skip lines torn by a crash rather than failing the whole report
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		if !rec.Time.Before(since) {
+			records = append(records, rec)
+		}
+	}
+	return records, scanner.Err()
+}
+
+// defaultPrices are list prices in USD per million tokens, keyed by model
+// name prefix; the longest matching prefix wins, so list cheaper variants
+// such as o3-mini separately. They go stale; the `prices` config section
+// overrides them.
+var defaultPrices = map[string]config.Price{
+	"gpt-5":                  {Input: 1.25, Output: 10},
+	"gpt-5-mini":             {Input: 0.25, Output: 2},
+	"gpt-4.1":                {Input: 2, Output: 8},
+	"gpt-4.1-mini":           {Input: 0.40, Output: 1.60},
+	"gpt-4o":                 {Input: 2.50, Output: 10},
+	"gpt-4o-mini":            {Input: 0.15, Output: 0.60},
+	"gpt-5-nano":             {Input: 0.05, Output: 0.40},
+	"gpt-4.1-nano":           {Input: 0.10, Output: 0.40},
+	"o1":                     {Input: 15, Output: 60},
+	"o1-mini":                {Input: 1.10, Output: 4.40},
+	"o3":                     {Input: 2, Output: 8},
+	"o3-mini":                {Input: 1.10, Output: 4.40},
+	"o3-pro":                 {Input: 20, Output: 80},
+	"o4-mini":                {Input: 1.10, Output: 4.40},
+	"claude-opus-4":          {Input: 15, Output: 75},
+	"claude-sonnet-4":        {Input: 3, Output: 15},
+	"claude-3-7-sonnet":      {Input: 3, Output: 15},
+	"claude-3-5-sonnet":      {Input: 3, Output: 15},
+	"claude-3-5-haiku":       {Input: 0.80, Output: 4},
+	"gemini-2.5-pro":         {Input: 1.25, Output: 10},
+	"gemini-2.5-flash":       {Input: 0.30, Output: 2.50},
+	"gemini-1.5-pro":         {Input: 1.25, Output: 5},
+	"gemini-1.5-flash":       {Input: 0.075, Output: 0.30},
+	"text-embedding-3-small": {Input: 0.02},
+	"text-embedding-3-large": {Input: 0.13},
+}
+
+// Cost estimates the price of rec in USD. ok is false when the model has
+// no known price. Local Ollama models are free; older records carry no
+// kind, only a provider name.
+func Cost(prices map[string]config.Price, rec Record) (cost float64, ok bool) {
+	if rec.Kind == "ollama" || (rec.Kind == "" && rec.Provider == "ollama") {
+		return 0, true
+	}
+	price, ok := lookupPrice(prices, rec.Model)
+	if !ok {
+		return 0, false
+	}
+	return (float64(rec.InputTokens)*price.Input + float64(rec.OutputTokens)*price.Output) / 1e6, true
+}
+
+func lookupPrice(prices map[string]config.Price, model string) (config.Price, bool) {
+	name := strings.TrimPrefix(strings.ToLower(model), "models/")
+	for _, table := range []map[string]config.Price{prices, defaultPrices} {
+		best := 0
+		var found config.Price
+		for prefix, p := range table {
+			if strings.HasPrefix(name, strings.ToLower(prefix)) && len(prefix) > best {
+				best, found = len(prefix), p
+			}
+		}
+		if best > 0 {
+			return found, true
+		}
+	}
+	return config.Price{}, false
+}
+
+// Row is one line of a usage report.
+type Row struct {
+	Key          string
+	Calls        int
+	InputTokens  int
+	OutputTokens int
+	Cost         float64
+	// Unpriced counts calls whose model has no known price, so Cost is a lower bound.
+	Unpriced int
+}
+
+// Summarize groups records by key, sorted by key.
+func Summarize(records []Record, prices map[string]config.Price, key func(Record) string) []Row {
+	rows := make(map[string]*Row)
+	for _, rec := range records {
+		k := key(rec)
+		row, ok := rows[k]
+		if !ok {
+			row = &Row{Key: k}
+			rows[k] = row
+		}
+		row.Calls++
+		row.InputTokens += rec.InputTokens
+		row.OutputTokens += rec.OutputTokens
+		if cost, ok := Cost(prices, rec); ok {
+			row.Cost += cost
+		} else {
+			row.Unpriced++
+		}
+	}
+
+	out := make([]Row, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, *row)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Key < out[j].Key
+	})
+	return out
+}
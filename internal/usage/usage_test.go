@@ -0,0 +1,47 @@
+package usage
+
+import (
+	"testing"
+
+	"github.com/eliau2005/ai-git/internal/config"
+)
+
+func TestCost(t *testing.T) {
+	tests := []struct {
+		name   string
+		rec    Record
+		want   float64
+		wantOK bool
+	}{
+		{name: "priced", rec: Record{Kind: "openai", Provider: "work", Model: "gpt-4o-mini", InputTokens: 1e6, OutputTokens: 1e6}, want: 0.75, wantOK: true},
+		{name: "named ollama entry", rec: Record{Kind: "ollama", Provider: "gpu-box", Model: "llama3", InputTokens: 1e6}, wantOK: true},
+		{name: "old ollama record", rec: Record{Provider: "ollama", Model: "llama3", InputTokens: 1e6}, wantOK: true},
+		{name: "openai-compatible entry named ollama", rec: Record{Kind: "openai", Provider: "ollama", Model: "llama3", InputTokens: 1e6}},
+		{name: "unknown model", rec: Record{Kind: "openai", Provider: "openai", Model: "mystery"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Cost(nil, tt.rec)
+			if ok != tt.wantOK || got < tt.want-1e-9 || got > tt.want+1e-9 {
+				t.Errorf("Cost = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	prices := map[string]config.Price{"llama3": {Input: 1}}
+	if got, ok := Cost(prices, Record{Kind: "ollama", Provider: "local", Model: "llama3", InputTokens: 1e6}); got != 0 || !ok {
+		t.Errorf("an ollama entry costs %v, %v, want it free", got, ok)
+	}
+}
+
+func TestSummarizeByEntry(t *testing.T) {
+	records := []Record{
+		{Kind: "ollama", Provider: "local", Model: "llama3", InputTokens: 10},
+		{Kind: "ollama", Provider: "gpu-box", Model: "llama3", InputTokens: 20},
+		{Kind: "ollama", Provider: "gpu-box", Model: "llama3", InputTokens: 5},
+	}
+	rows := Summarize(records, nil, func(r Record) string { return r.Provider + "/" + r.Model })
+	if len(rows) != 2 || rows[0].Key != "gpu-box/llama3" || rows[0].InputTokens != 25 || rows[1].InputTokens != 10 {
+		t.Errorf("rows = %+v, want the two ollama entries apart", rows)
+	}
+}